@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/audit"
+	"mcp/internal/integrations"
+	"mcp/internal/integrations/sql"
+	localbroker "mcp/internal/local_broker"
+	"mcp/internal/registry"
+	serverrunner "mcp/internal/server_runner"
+	docker_runner "mcp/internal/server_runner/docker"
+	remote_runner "mcp/internal/server_runner/remote"
+	"mcp/internal/suggestions"
+	"mcp/internal/util"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/spf13/viper"
+)
+
+// brokerDeps holds everything a local broker needs, independently of the
+// transport used to reach it.
+type brokerDeps struct {
+	integRepo       integrations.IntegrationsRepository
+	suggestionsRepo suggestions.SuggestionsRepository
+	auditLog        audit.AuditLog
+	runner          serverrunner.ServerStarter
+	registry        registry.RegistryClient
+	options         localbroker.LocalBrokerOptions
+
+	disposer *util.MovableDisposer
+}
+
+// openBrokerDeps opens the database, starts the runners and reads the broker
+// options from the configuration.
+func openBrokerDeps(ctx context.Context) (*brokerDeps, error) {
+	var deps brokerDeps
+
+	disposer := &util.MovableDisposer{}
+	defer disposer.Dispose()
+
+	restartPolicy, err := localbroker.ParseRestartPolicy(viper.GetString("restart_policy"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	deps.options = localbroker.LocalBrokerOptions{
+		MountRoots:     viper.GetBool("mount_roots"),
+		RestartPolicy:  restartPolicy,
+		ValidateOutput: viper.GetBool("validate_output"),
+	}
+
+	dsn := viper.GetString("db")
+	logger.Debug("using database", "dsn", dsn)
+
+	db, err := sql.OpenDatabase(ctx, logger, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening database: %w", err)
+	}
+	disposer.DeferWithError(db.Close)
+
+	// The repositories share the database's connection pool.
+	deps.integRepo = sql.NewSQLDatabaseIntegrationsRepository(logger, db)
+	deps.suggestionsRepo = sql.NewSQLDatabaseSuggestionsRepository(logger, db)
+	deps.auditLog = sql.NewSQLDatabaseAuditLog(logger, db)
+
+	logger.Debug("database up, starting docker runner")
+
+	dockerRunner, err := docker_runner.NewDockerServerRunner(ctx, logger, docker_runner.DockerServerOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error while creating docker server runner: %w", err)
+	}
+
+	logger.Debug("docker runner up")
+
+	runner := serverrunner.NewRuntimeDispatcher(map[string]serverrunner.ServerStarter{
+		"node":                      dockerRunner,
+		"python":                    dockerRunner,
+		serverrunner.RUNTIME_REMOTE: remote_runner.NewRemoteServerRunner(logger, remote_runner.RemoteServerOptions{}),
+	})
+	deps.runner = runner
+	disposer.DeferWithError(runner.Close)
+
+	deps.registry, err = registry.NewFakeClient(logger)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating registry client: %w", err)
+	}
+
+	deps.disposer = disposer.Move()
+
+	return &deps, nil
+}
+
+func (d *brokerDeps) Close() error {
+	return d.disposer.Dispose()
+}
+
+// newBroker creates a local broker, to be run and then handed the sessions of
+// its clients.
+func (d *brokerDeps) newBroker(ctx context.Context) localbroker.LocalBroker {
+	return localbroker.NewLocalBroker(ctx, logger, d.integRepo, d.runner, d.registry, d.suggestionsRepo, d.auditLog, d.options)
+}
+
+// serveSession serves a client's session with broker, treating the client
+// disconnecting as the session's normal end.
+func serveSession(ctx context.Context, broker localbroker.LocalBroker, stream jsonrpc2.ObjectStream) error {
+	if err := broker.ServeSession(ctx, stream); err != nil && err != localbroker.ErrConnectionClosed {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"mcp/internal/daemon"
+	localbroker "mcp/internal/local_broker"
+	"os"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	cmdDaemon = &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the broker shared by stdio clients, listening on a Unix socket.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return bindBrokerFlags(cmd)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-interrupts():
+					cancel()
+				}
+			}()
+
+			socketPath := viper.GetString("socket")
+			idleTimeout, err := cmd.Flags().GetDuration("idle-timeout")
+			cobra.CheckErr(err)
+
+			// The socket is taken before anything else so that concurrently
+			// spawned daemons give way to the first one.
+			var broker localbroker.LocalBroker
+			d, err := daemon.Listen(logger, socketPath, func(ctx context.Context, stream jsonrpc2.ObjectStream) error {
+				return serveSession(ctx, broker, stream)
+			}, daemon.DaemonOptions{
+				IdleTimeout: idleTimeout,
+				Metadata:    brokerFlagValues(),
+			})
+			if errors.Is(err, daemon.ErrAlreadyRunning) {
+				logger.Info("daemon already running", "socket", socketPath)
+				return
+			}
+			if err != nil {
+				logger.Error("error while listening", "socket", socketPath, "err", err)
+				os.Exit(1)
+			}
+			defer d.Close()
+
+			deps, err := openBrokerDeps(ctx)
+			if err != nil {
+				logger.Error("error while starting", "err", err)
+				d.Close()
+				os.Exit(1)
+			}
+			defer deps.Close()
+
+			// Every session is served by the same broker, and so shares its
+			// servers.
+			broker = deps.newBroker(ctx)
+			defer broker.Close()
+
+			go func() {
+				if err := broker.Run(ctx); err != nil {
+					logger.Error("error while running local broker", "err", err)
+					cancel()
+				}
+			}()
+
+			logger.Info("daemon listening", "socket", socketPath)
+
+			if err := d.Serve(ctx); err != nil {
+				logger.Error("error while running daemon", "err", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	cmdDaemon.Flags().Duration("idle-timeout", 0, "exit after having no client for that long, never when zero")
+	addBrokerFlags(cmdDaemon.Flags())
+}
@@ -1,14 +1,108 @@
 package main
 
-import "github.com/spf13/cobra"
+import (
+	"bufio"
+	"fmt"
+	"mcp/internal/integrations"
+	"mcp/internal/integrations/sql"
+	"mcp/internal/registry"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
 
 var (
 	cmdPackageInstall = &cobra.Command{
 		Use:     "install <package[@version]>",
 		Short:   "Install a package from the registry.",
 		Aliases: []string{"i"},
+		Args:    cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			ctx := cmd.Context()
+			name, version := parsePackageSpec(args[0])
+
+			rc, err := registry.NewFakeClient(logger)
+			cobra.CheckErr(err)
+
+			manifest, err := rc.GetIntegrationManifestByNameAndVersion(name, version)
+			cobra.CheckErr(err)
+
+			dsnURI := viper.GetString("db")
+			db, err := sql.OpenDatabase(ctx, logger, dsnURI)
+			cobra.CheckErr(err)
+			defer db.Close()
+
+			repo := sql.NewSQLDatabaseIntegrationsRepository(logger, db)
+
+			installed, err := repo.ListIntegrations(ctx)
+			cobra.CheckErr(err)
+
+			for _, integration := range installed {
+				if integration.Manifest.Name == manifest.Name {
+					cmd.PrintErrf("%s is already installed\n", manifest.Name)
+					return
+				}
+			}
+
+			env := make(map[string]string)
+			in := bufio.NewReader(cmd.InOrStdin())
+
+			for _, envVar := range manifest.EnvVars {
+				value, err := promptEnvVar(cmd, in, envVar)
+				cobra.CheckErr(err)
+
+				if value != "" {
+					env[envVar.Key] = value
+				}
+			}
+
+			if missing := integrations.MissingEnvVars(manifest, env); len(missing) > 0 {
+				cobra.CheckErr(fmt.Errorf("missing required configuration: %s", strings.Join(missing, ", ")))
+			}
+
+			_, err = repo.InstallIntegration(ctx, manifest, env)
+			cobra.CheckErr(err)
 
+			cmd.PrintErrf("Installed %s %s\n", manifest.Name, manifest.Version)
 		},
 	}
 )
+
+// parsePackageSpec splits a "package[@version]" argument, taking care of
+// scoped package names such as "@scope/package@1.0.0".
+func parsePackageSpec(spec string) (name string, version string) {
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		return spec[:i], spec[i+1:]
+	}
+
+	return spec, "latest"
+}
+
+// promptEnvVar asks the user for the value of an environment variable,
+// defaulting to the value found in the current environment.
+func promptEnvVar(cmd *cobra.Command, in *bufio.Reader, envVar registry.EnvVar) (string, error) {
+	current := os.Getenv(envVar.Key)
+
+	label := envVar.Key
+	if !envVar.Required {
+		label += " (optional)"
+	}
+	if current != "" {
+		label += " [from environment]"
+	}
+
+	cmd.PrintErrf("%s: ", label)
+
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return current, nil
+	}
+
+	if value := strings.TrimSpace(line); value != "" {
+		return value, nil
+	}
+
+	return current, nil
+}
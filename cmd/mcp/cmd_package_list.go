@@ -14,8 +14,11 @@ var (
 		Aliases: []string{"ls"},
 		Run: func(cmd *cobra.Command, args []string) {
 			dsnURI := viper.GetString("db")
-			repo, err := sql.NewSQLDatabaseIntegrationsRepository(cmd.Context(), logger, dsnURI)
+			db, err := sql.OpenDatabase(cmd.Context(), logger, dsnURI)
 			cobra.CheckErr(err)
+			defer db.Close()
+
+			repo := sql.NewSQLDatabaseIntegrationsRepository(logger, db)
 
 			intalled, err := repo.ListIntegrations(cmd.Context())
 			cobra.CheckErr(err)
@@ -28,9 +28,14 @@ func init() {
 
 	cmdRoot.PersistentFlags().StringVar(&logLevelArg, "log-level", "info", "log level among \"debug\", \"info\" or \"error\"")
 
+	cmdRoot.PersistentFlags().String("socket", "", "path of the daemon's Unix socket (default ~/.mcp/mcp.sock)")
+	cobra.CheckErr(viper.BindPFlag("socket", cmdRoot.PersistentFlags().Lookup("socket")))
+
+	cmdRoot.AddCommand(cmdDaemon)
 	cmdRoot.AddCommand(cmdPackage)
 	cmdRoot.AddCommand(cmdRegistry)
 	cmdRoot.AddCommand(cmdServe)
+	cmdRoot.AddCommand(cmdSuggestions)
 }
 
 func initConfig() {
@@ -58,6 +63,7 @@ func initConfig() {
 
 	viper.SetDefault("logfile", path.Join(cfgDir, "debug.log"))
 	viper.SetDefault("db", path.Join(cfgDir, "mcp.db"))
+	viper.SetDefault("socket", path.Join(cfgDir, "mcp.sock"))
 
 	viper.AutomaticEnv()
 
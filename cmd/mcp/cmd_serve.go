@@ -1,16 +1,66 @@
 package main
 
 import (
+	"fmt"
+	localbroker "mcp/internal/local_broker"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 var (
 	cmdServe = &cobra.Command{
 		Use:   "serve",
 		Short: "Commands to start mcp as a server.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return bindBrokerFlags(cmd)
+		},
 	}
 )
 
 func init() {
+	addBrokerFlags(cmdServe.PersistentFlags())
+
 	cmdServe.AddCommand(cmdServeStdio)
+	cmdServe.AddCommand(cmdServeHttp)
+}
+
+// brokerFlags maps the flags configuring the local broker to their
+// configuration keys.
+var brokerFlags = map[string]string{
+	"mount-roots":     "mount_roots",
+	"restart-policy":  "restart_policy",
+	"validate-output": "validate_output",
+}
+
+// addBrokerFlags registers the flags configuring the local broker.
+func addBrokerFlags(flags *pflag.FlagSet) {
+	flags.Bool("mount-roots", false, "bind-mount the client's roots into the containers of child servers")
+	flags.String("restart-policy", string(localbroker.RestartPolicyOnUnhealthy), "what to do with servers that stop responding or exit, among \"on-unhealthy\" or \"never\"")
+	flags.Bool("validate-output", false, "validate the structured content returned by tools against their output schemas")
+}
+
+// brokerFlagValues returns the values of the broker flags, as they would be
+// passed on the command line.
+func brokerFlagValues() map[string]string {
+	values := make(map[string]string, len(brokerFlags))
+	for flag, key := range brokerFlags {
+		values[flag] = fmt.Sprint(viper.Get(key))
+	}
+
+	return values
+}
+
+// bindBrokerFlags binds the broker flags of the command being run to their
+// configuration keys. This can't happen when the flags are registered since
+// several commands define them and a key is bound to a single flag.
+func bindBrokerFlags(cmd *cobra.Command) error {
+	for flag, key := range brokerFlags {
+		if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	httptransport "mcp/internal/http_transport"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DEFAULT_HTTP_ADDR only listens on the loopback interface: the broker
+	// runs commands on behalf of its clients and must not be exposed.
+	DEFAULT_HTTP_ADDR = "127.0.0.1:8080"
+
+	// HTTP_ENDPOINT_PATH is the path of the MCP endpoint.
+	HTTP_ENDPOINT_PATH = "/mcp"
+
+	// The paths of the event stream and message endpoints of the HTTP+SSE
+	// transport, for clients that predate streamable HTTP.
+	HTTP_LEGACY_SSE_PATH     = "/sse"
+	HTTP_LEGACY_MESSAGE_PATH = "/message"
+
+	HTTP_SHUTDOWN_TIMEOUT_SECONDS = 10
+)
+
+var (
+	cmdServeHttp = &cobra.Command{
+		Use:   "http",
+		Short: "Start mcp as a streamable HTTP server.",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			g, ctx := errgroup.WithContext(ctx)
+
+			deps, err := openBrokerDeps(ctx)
+			if err != nil {
+				logger.Error("error while starting", "err", err)
+				os.Exit(1)
+			}
+			defer deps.Close()
+
+			// Every session is served by the same broker, and so shares its
+			// servers.
+			broker := deps.newBroker(ctx)
+			defer broker.Close()
+
+			g.Go(func() error {
+				return broker.Run(ctx)
+			})
+
+			transport := httptransport.NewServer(ctx, logger, func(ctx context.Context, stream jsonrpc2.ObjectStream) error {
+				return serveSession(ctx, broker, stream)
+			}, httptransport.ServerOptions{
+				AllowedOrigins: viper.GetStringSlice("http_allowed_origins"),
+			})
+			defer transport.Close()
+
+			mux := http.NewServeMux()
+			mux.Handle(HTTP_ENDPOINT_PATH, transport)
+			mux.Handle(HTTP_LEGACY_SSE_PATH, transport.LegacySSEHandler(HTTP_LEGACY_MESSAGE_PATH))
+			mux.Handle(HTTP_LEGACY_MESSAGE_PATH, transport.LegacyMessageHandler())
+
+			addr := viper.GetString("http_addr")
+
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				logger.Error("error while listening", "addr", addr, "err", err)
+				os.Exit(1)
+			}
+
+			srv := &http.Server{
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			logger.Info("serving MCP over HTTP", "url", "http://"+listener.Addr().String()+HTTP_ENDPOINT_PATH)
+
+			g.Go(func() error {
+				if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return err
+				}
+
+				return nil
+			})
+
+			g.Go(func() error {
+				select {
+				case <-ctx.Done():
+				case <-interrupts():
+				}
+
+				// Ending the sessions first lets the event streams still open
+				// finish so that the server can shut down gracefully.
+				transport.Close()
+
+				shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), HTTP_SHUTDOWN_TIMEOUT_SECONDS*time.Second)
+				defer cancelShutdown()
+
+				err := srv.Shutdown(shutdownCtx)
+
+				// Stop the broker along with the server.
+				cancel()
+
+				return err
+			})
+
+			if err := g.Wait(); err != nil && err != context.Canceled {
+				logger.Error("error while running server", "err", err)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	cmdServeHttp.Flags().String("addr", DEFAULT_HTTP_ADDR, "address to listen on")
+	cobra.CheckErr(viper.BindPFlag("http_addr", cmdServeHttp.Flags().Lookup("addr")))
+
+	cmdServeHttp.Flags().StringSlice("allowed-origin", nil, "origin allowed to connect besides those of the local host, may be repeated")
+	cobra.CheckErr(viper.BindPFlag("http_allowed_origins", cmdServeHttp.Flags().Lookup("allowed-origin")))
+}
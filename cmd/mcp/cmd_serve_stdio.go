@@ -2,23 +2,35 @@ package main
 
 import (
 	"context"
-	"mcp/internal/integrations/sql"
+	"fmt"
+	"io"
+	"mcp/internal/daemon"
 	localbroker "mcp/internal/local_broker"
-	docker_runner "mcp/internal/server_runner/docker"
+	"mcp/internal/util"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+const (
+	// DEFAULT_SPAWNED_DAEMON_IDLE_TIMEOUT_MINUTES is how long a daemon spawned
+	// by a stdio client keeps running after its last client went away.
+	DEFAULT_SPAWNED_DAEMON_IDLE_TIMEOUT_MINUTES = 10
+)
+
 var (
+	serveStdioNoDaemon bool
+
 	cmdServeStdio = &cobra.Command{
 		Use:   "stdio",
-		Short: "Start mcp as a stdio server.",
+		Short: "Start mcp as a stdio server, backed by the shared daemon.",
 		Run: func(cmd *cobra.Command, args []string) {
 			ctx, cancel := context.WithCancel(cmd.Context())
 			defer cancel()
@@ -35,40 +47,11 @@ var (
 			})
 
 			g.Go(func() error {
-				dsn := viper.GetString("db")
-				logger.Debug("using database", "dsn", dsn)
-				integRepo, err := sql.NewSQLDatabaseIntegrationsRepository(ctx, logger, dsn)
-				if err != nil {
-					logger.Error("error while creating integrations repository", "err", err)
-					os.Exit(1)
-				}
-				defer integRepo.Close()
-
-				logger.Debug("database up, starting docker runner")
-
-				runner, err := docker_runner.NewDockerServerRunner(ctx, logger, docker_runner.DockerServerOptions{})
-				if err != nil {
-					logger.Error("error while creating docker server runner", "err", err)
-					os.Exit(1)
-				}
-				defer runner.Close()
-
-				logger.Debug("docker runner up, starting local broker")
-
-				broker := localbroker.NewLocalBroker(ctx, logger, integRepo, runner, os.Stdin, os.Stdout)
-				defer broker.Close()
-
-				if err := broker.Run(ctx); err != nil {
-					if err != localbroker.ErrConnectionClosed {
-						logger.Error("error while running local broker", "err", err)
-					}
-
-					return err
+				if serveStdioNoDaemon {
+					return serveStdioInProcess(ctx)
 				}
 
-				logger.Debug("local broker finished")
-
-				return nil
+				return serveStdioViaDaemon(ctx)
 			})
 
 			if err := g.Wait(); err != nil && err != context.Canceled && err != localbroker.ErrConnectionClosed {
@@ -81,6 +64,119 @@ var (
 	}
 )
 
+func init() {
+	cmdServeStdio.Flags().BoolVar(&serveStdioNoDaemon, "no-daemon", false, "run the broker and its servers in this process instead of the shared daemon")
+}
+
+// serveStdioInProcess runs a local broker of its own for the client.
+func serveStdioInProcess(ctx context.Context) error {
+	deps, err := openBrokerDeps(ctx)
+	if err != nil {
+		logger.Error("error while starting", "err", err)
+		os.Exit(1)
+	}
+	defer deps.Close()
+
+	logger.Debug("starting local broker")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	broker := deps.newBroker(ctx)
+	defer broker.Close()
+
+	go func() {
+		if err := broker.Run(ctx); err != nil {
+			logger.Error("error while running local broker", "err", err)
+		}
+	}()
+
+	if err := broker.ServeSession(ctx, jsonrpc2.NewPlainObjectStream(util.NewReaderWriterCloser(os.Stdin, os.Stdout))); err != nil {
+		if err != localbroker.ErrConnectionClosed {
+			logger.Error("error while running local broker", "err", err)
+		}
+
+		return err
+	}
+
+	logger.Debug("local broker finished")
+
+	return nil
+}
+
+// serveStdioViaDaemon relays the client's messages to the shared daemon,
+// spawning it when it isn't running yet.
+func serveStdioViaDaemon(ctx context.Context) error {
+	socketPath := viper.GetString("socket")
+
+	conn, err := daemon.Connect(ctx, logger, socketPath, spawnDaemon)
+	if err != nil {
+		logger.Error("error while connecting to daemon", "socket", socketPath, "err", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	logger.Debug("connected to daemon", "socket", socketPath)
+
+	warnOnDaemonFlagsMismatch(socketPath)
+
+	go func() {
+		if _, err := io.Copy(conn, os.Stdin); err != nil {
+			logger.Error("error relaying messages to daemon", "err", err)
+		}
+
+		// Let the daemon end the session once the client is gone.
+		if conn, ok := conn.(interface{ CloseWrite() error }); ok {
+			conn.CloseWrite()
+		}
+	}()
+
+	if _, err := io.Copy(os.Stdout, conn); err != nil {
+		return fmt.Errorf("error relaying messages from daemon: %w", err)
+	}
+
+	return localbroker.ErrConnectionClosed
+}
+
+// warnOnDaemonFlagsMismatch warns when the daemon was started with broker flags
+// differing from those of this process, which it ignores as the daemon's apply
+// to every client.
+func warnOnDaemonFlagsMismatch(socketPath string) {
+	metadata, err := daemon.ReadMetadata(socketPath)
+	if err != nil {
+		logger.Warn("error checking the daemon's broker flags", "socket", socketPath, "err", err)
+		return
+	}
+
+	for flag, value := range brokerFlagValues() {
+		if daemonValue := metadata[flag]; daemonValue != value {
+			logger.Warn("the running daemon uses a different value for a broker flag, stop it for this one to apply", "flag", flag, "value", value, "daemonValue", daemonValue)
+		}
+	}
+}
+
+// spawnDaemon starts a daemon that exits once it no longer serves any client,
+// configured like this process.
+func spawnDaemon() (<-chan error, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"daemon",
+		"--log-level", logLevelArg,
+		"--socket", viper.GetString("socket"),
+		"--idle-timeout", (DEFAULT_SPAWNED_DAEMON_IDLE_TIMEOUT_MINUTES * time.Minute).String(),
+	}
+
+	for flag, value := range brokerFlagValues() {
+		args = append(args, fmt.Sprintf("--%s=%s", flag, value))
+	}
+
+	return daemon.Spawn(executable, args...)
+}
+
 // interrupts returns a channel that is closed when an interrupt signal is received.
 func interrupts() <-chan struct{} {
 	c := make(chan struct{})
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/spf13/cobra"
+
+var (
+	cmdSuggestions = &cobra.Command{
+		Use:     "suggestions",
+		Aliases: []string{"sug"},
+		Short:   "Review the tools that models have suggested.",
+	}
+)
+
+func init() {
+	cmdSuggestions.AddCommand(cmdSuggestionsList)
+}
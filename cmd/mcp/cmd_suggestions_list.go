@@ -0,0 +1,44 @@
+package main
+
+import (
+	"mcp/internal/integrations/sql"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	cmdSuggestionsList = &cobra.Command{
+		Use:     "list",
+		Short:   "List suggested tools, most frequently suggested first.",
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			dsnURI := viper.GetString("db")
+			db, err := sql.OpenDatabase(cmd.Context(), logger, dsnURI)
+			cobra.CheckErr(err)
+			defer db.Close()
+
+			repo := sql.NewSQLDatabaseSuggestionsRepository(logger, db)
+
+			found, err := repo.ListSuggestions(cmd.Context())
+			cobra.CheckErr(err)
+
+			if len(found) == 0 {
+				cmd.PrintErrf("No tools suggested\n")
+				return
+			}
+
+			cmd.PrintErrf("Found %d suggested tools\n", len(found))
+
+			for _, suggestion := range found {
+				cmd.PrintErrf("\n")
+				cmd.PrintErrf("%s (suggested %d times, last on %s)\n", suggestion.Name, suggestion.Count, suggestion.LastSuggestedAt.Local().Format(time.DateTime))
+				if suggestion.Description != "" {
+					cmd.PrintErrf("  %s\n", suggestion.Description)
+				}
+				cmd.PrintErrf("  first suggested in session %s\n", suggestion.SessionId)
+			}
+		},
+	}
+)
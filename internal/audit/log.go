@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"context"
+)
+
+type RecordType string
+
+const (
+	RecordTypeRequest      RecordType = "request"
+	RecordTypeResponse     RecordType = "response"
+	RecordTypeNotification RecordType = "notification"
+)
+
+// Record is a single JSON-RPC operation going through the broker.
+type Record struct {
+	SessionId string
+	Type      RecordType
+
+	// Origin identifies the party that initiated the operation, such as the
+	// prefix of the child server that issued a request.
+	Origin string
+
+	RequestId string
+	Operation any
+}
+
+type AuditLog interface {
+	Close() error
+
+	Record(ctx context.Context, r *Record) error
+}
@@ -28,6 +28,10 @@ type Client struct {
 
 	runner serverrunner.ServerStarter
 
+	// Handler handles requests initiated by the server, such as sampling
+	// requests. When nil, they are rejected as not found.
+	Handler serverrunner.RequestHandler
+
 	cmd    *exec.Cmd
 	conn   *jsonrpc2.Conn
 	cancel context.CancelFunc
@@ -71,21 +75,20 @@ func (c *Client) Start(ctx context.Context, id string, logger *slog.Logger) erro
 
 	c.cmd.Start()
 
-	var initializeResult mcp.InitializeResult
-	if err := c.conn.Call(ctx, "initialize", &mcp.InitializeRequest{}, &initializeResult); err != nil {
-		logger.Error("failed to initialize client", "err", err)
-		cancel()
-		return err
+	var capabilities mcp.ClientCapabilities
+	if c.Handler != nil {
+		capabilities.Sampling = &mcp.SamplingCapability{}
 	}
 
-	logger.Info("child MCP server initialized", "id", id, "name", c.Server.Name)
-
-	if err := c.conn.Notify(ctx, "initialized", &mcp.InitializedNotification{}); err != nil {
-		logger.Error("failed to notify client initialization", "err", err)
+	serverConn, err := serverrunner.Initialize(ctx, c.conn, capabilities)
+	if err != nil {
+		logger.Error("failed to initialize client", "err", err)
 		cancel()
 		return err
 	}
 
+	logger.Info("child MCP server initialized", "id", id, "name", c.Server.Name, "protocolVersion", serverConn.InitializeResult().ProtocolVersion)
+
 	var toolsListResult mcp.ToolsListResult
 	if err := c.conn.Call(ctx, "tools/list", &mcp.ToolsListRequest{}, &toolsListResult); err != nil {
 		logger.Error("failed to list tools", "err", err)
@@ -101,6 +104,14 @@ func (c *Client) Close() {
 }
 
 func (c *Client) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	if req.Method == "ping" {
+		return &mcp.EmptyResult{}, nil
+	}
+
+	if c.Handler != nil {
+		return c.Handler(ctx, req)
+	}
+
 	return nil, &jsonrpc2.Error{
 		Code:    jsonrpc2.CodeMethodNotFound,
 		Message: fmt.Sprintf("method %q not found", req.Method),
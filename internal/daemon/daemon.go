@@ -0,0 +1,314 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// DEFAULT_SPAWN_TIMEOUT_SECONDS is how long to wait for a daemon that was
+	// just spawned to accept connections. It covers the time needed to open
+	// the database and connect to docker.
+	DEFAULT_SPAWN_TIMEOUT_SECONDS = 30
+
+	SPAWN_POLL_INTERVAL = 100 * time.Millisecond
+)
+
+var ErrAlreadyRunning = fmt.Errorf("daemon already running")
+
+// SessionHandler serves an MCP session, reading the client's messages from
+// stream and writing its own to it. The session ends when it returns.
+type SessionHandler func(ctx context.Context, stream jsonrpc2.ObjectStream) error
+
+type DaemonOptions struct {
+	// IdleTimeout makes the daemon exit once it has had no session for that
+	// long. The daemon runs until cancelled when zero.
+	IdleTimeout time.Duration
+	// Metadata describes how the daemon is configured, for clients to check
+	// with ReadMetadata that it matches what they expect.
+	Metadata map[string]string
+}
+
+// Daemon accepts connections on a Unix socket, each of them carrying an MCP
+// session.
+type Daemon struct {
+	logger       *slog.Logger
+	handler      SessionHandler
+	options      DaemonOptions
+	listener     net.Listener
+	metadataPath string
+	unlock       func() error
+
+	mu       sync.Mutex
+	sessions int
+	idle     *time.Timer
+	idleOut  bool
+	wg       sync.WaitGroup
+}
+
+// Listen takes ownership of the socket at socketPath, replacing any stale
+// socket left behind by a daemon that didn't exit cleanly. It fails with
+// ErrAlreadyRunning when another daemon owns the socket.
+func Listen(logger *slog.Logger, socketPath string, handler SessionHandler, options DaemonOptions) (*Daemon, error) {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return nil, fmt.Errorf("error creating socket directory: %w", err)
+	}
+
+	unlock, err := lock(socketPath + ".lock")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		unlock()
+		return nil, fmt.Errorf("error removing stale socket: %w", err)
+	}
+
+	// The metadata is written before listening so that clients connecting
+	// can read it.
+	metadata, err := json.Marshal(options.Metadata)
+	if err != nil {
+		unlock()
+		return nil, fmt.Errorf("error encoding daemon metadata: %w", err)
+	}
+
+	metadataPath := metadataFile(socketPath)
+	if err := os.WriteFile(metadataPath, metadata, 0600); err != nil {
+		unlock()
+		return nil, fmt.Errorf("error writing daemon metadata: %w", err)
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.Remove(metadataPath)
+		unlock()
+		return nil, fmt.Errorf("error listening on %q: %w", socketPath, err)
+	}
+
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		listener.Close()
+		os.Remove(metadataPath)
+		unlock()
+		return nil, fmt.Errorf("error restricting access to socket: %w", err)
+	}
+
+	return &Daemon{
+		logger:       logger,
+		handler:      handler,
+		options:      options,
+		listener:     listener,
+		metadataPath: metadataPath,
+		unlock:       unlock,
+	}, nil
+}
+
+func metadataFile(socketPath string) string {
+	return socketPath + ".json"
+}
+
+// ReadMetadata returns the metadata of the daemon listening at socketPath.
+func ReadMetadata(socketPath string) (map[string]string, error) {
+	b, err := os.ReadFile(metadataFile(socketPath))
+	if err != nil {
+		return nil, fmt.Errorf("error reading daemon metadata: %w", err)
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(b, &metadata); err != nil {
+		return nil, fmt.Errorf("error decoding daemon metadata: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// Serve runs a session for each connection until ctx is cancelled or the
+// daemon has been idle for longer than its idle timeout. It waits for the
+// sessions to end before returning.
+func (d *Daemon) Serve(ctx context.Context) error {
+	// Sessions are cancelled before being waited for.
+	defer d.wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		d.listener.Close()
+	}()
+
+	d.mu.Lock()
+	d.resetIdleTimer()
+	d.mu.Unlock()
+
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			d.mu.Lock()
+			idleOut := d.idleOut
+			d.mu.Unlock()
+
+			if ctx.Err() != nil || idleOut {
+				return nil
+			}
+
+			return fmt.Errorf("error accepting connection: %w", err)
+		}
+
+		d.mu.Lock()
+		d.sessions++
+		if d.idle != nil {
+			d.idle.Stop()
+		}
+		d.mu.Unlock()
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (d *Daemon) serveConn(ctx context.Context, conn net.Conn) {
+	defer func() {
+		d.mu.Lock()
+		d.sessions--
+		d.resetIdleTimer()
+		d.mu.Unlock()
+	}()
+
+	d.logger.Debug("session started")
+
+	stream := jsonrpc2.NewPlainObjectStream(conn)
+	defer stream.Close()
+
+	if err := d.handler(ctx, stream); err != nil && !errors.Is(err, context.Canceled) {
+		d.logger.Warn("session handler finished with an error", "err", err)
+	}
+
+	d.logger.Debug("session ended")
+}
+
+// resetIdleTimer arms the idle timer when there is no session left. The
+// caller must hold d.mu.
+func (d *Daemon) resetIdleTimer() {
+	if d.options.IdleTimeout <= 0 || d.sessions > 0 {
+		return
+	}
+
+	if d.idle != nil {
+		d.idle.Stop()
+	}
+
+	d.idle = time.AfterFunc(d.options.IdleTimeout, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		if d.sessions > 0 {
+			return
+		}
+
+		d.logger.Info("no session left, exiting", "idle_timeout", d.options.IdleTimeout)
+
+		d.idleOut = true
+		d.listener.Close()
+	})
+}
+
+// Close stops accepting connections and releases the socket.
+func (d *Daemon) Close() error {
+	d.mu.Lock()
+	if d.idle != nil {
+		d.idle.Stop()
+	}
+	d.mu.Unlock()
+
+	err := d.listener.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+
+	if removeErr := os.Remove(d.metadataPath); err == nil && !errors.Is(removeErr, os.ErrNotExist) {
+		err = removeErr
+	}
+
+	if unlockErr := d.unlock(); err == nil {
+		err = unlockErr
+	}
+
+	return err
+}
+
+// Dial connects to the daemon listening at socketPath.
+func Dial(ctx context.Context, socketPath string) (net.Conn, error) {
+	var dialer net.Dialer
+
+	return dialer.DialContext(ctx, "unix", socketPath)
+}
+
+// Connect connects to the daemon listening at socketPath, calling spawn to
+// start one in the background when none is running. Spawn returns a channel
+// receiving the daemon's exit status, such as the one returned by Spawn.
+func Connect(ctx context.Context, logger *slog.Logger, socketPath string, spawn func() (<-chan error, error)) (net.Conn, error) {
+	conn, err := Dial(ctx, socketPath)
+	if err == nil {
+		return conn, nil
+	}
+
+	logger.Info("no daemon running, spawning one", "socket", socketPath, "err", err)
+
+	exited, err := spawn()
+	if err != nil {
+		return nil, fmt.Errorf("error spawning daemon: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DEFAULT_SPAWN_TIMEOUT_SECONDS*time.Second)
+	defer cancel()
+
+	ticker := time.NewTicker(SPAWN_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for the daemon to start, last error: %w", err)
+		case exitErr := <-exited:
+			// A daemon exiting successfully gave way to another one that was
+			// spawned concurrently, which is still worth waiting for.
+			if exitErr != nil {
+				return nil, fmt.Errorf("daemon exited before accepting connections: %w", exitErr)
+			}
+			exited = nil
+		case <-ticker.C:
+		}
+
+		if conn, err = Dial(ctx, socketPath); err == nil {
+			return conn, nil
+		}
+	}
+}
+
+// start starts cmd, reporting its exit status on the returned channel.
+func start(cmd *exec.Cmd) (<-chan error, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	return exited, nil
+}
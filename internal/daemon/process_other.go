@@ -0,0 +1,19 @@
+//go:build !unix
+
+package daemon
+
+import (
+	"os/exec"
+)
+
+// lock is a no-op where file locks aren't available: the socket is simply
+// taken over by the last daemon started.
+func lock(path string) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+// Spawn starts the daemon in the background. The returned channel receives
+// the daemon's exit status.
+func Spawn(executable string, args ...string) (<-chan error, error) {
+	return start(exec.Command(executable, args...))
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// lock takes an exclusive lock on the file at path, so that only one daemon
+// owns the socket next to it at a time. The lock is released when the
+// returned function is called or the process exits.
+func lock(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrAlreadyRunning
+		}
+
+		return nil, fmt.Errorf("error locking %q: %w", path, err)
+	}
+
+	return f.Close, nil
+}
+
+// Spawn starts the daemon in the background, in its own session so that it
+// outlives the process spawning it and isn't sent the signals of its
+// terminal. The returned channel receives the daemon's exit status.
+func Spawn(executable string, args ...string) (<-chan error, error) {
+	cmd := exec.Command(executable, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	return start(cmd)
+}
@@ -0,0 +1,767 @@
+package httptransport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp/internal/mcp"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// CLIENT_LISTEN_RETRY_INTERVAL is how long a client waits before reopening
+	// the standalone stream of its session after it was interrupted.
+	CLIENT_LISTEN_RETRY_INTERVAL = time.Second
+
+	// CLIENT_CLOSE_TIMEOUT_SECONDS bounds how long a client closing its
+	// session waits for the server to acknowledge it.
+	CLIENT_CLOSE_TIMEOUT_SECONDS = 5
+
+	// MAX_ERROR_BODY_BYTES is how much of the body of an error response is
+	// reported.
+	MAX_ERROR_BODY_BYTES = 1024
+)
+
+// ErrSessionExpired is returned when the server no longer knows the session
+// of a client, which then has to start over.
+var ErrSessionExpired = errors.New("session expired")
+
+type ClientOptions struct {
+	// HTTPClient sends the requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Header is sent with every request, for instance to authenticate the
+	// client.
+	Header http.Header
+}
+
+// clientStream holds what the client streams of both transports share. The
+// messages read from the server are queued on incoming.
+type clientStream struct {
+	logger  *slog.Logger
+	options ClientOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	incoming chan json.RawMessage
+}
+
+func newClientStream(ctx context.Context, logger *slog.Logger, options ClientOptions) clientStream {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return clientStream{
+		logger:   logger,
+		options:  options,
+		ctx:      ctx,
+		cancel:   cancel,
+		incoming: make(chan json.RawMessage),
+	}
+}
+
+// ReadObject implements jsonrpc2.ObjectStream, yielding the messages sent by
+// the server.
+func (s *clientStream) ReadObject(v interface{}) error {
+	select {
+	case <-s.ctx.Done():
+		return io.EOF
+	case raw := <-s.incoming:
+		return json.Unmarshal(raw, v)
+	}
+}
+
+// receive queues a message sent by the server until it is read.
+func (s *clientStream) receive(raw json.RawMessage) {
+	select {
+	case <-s.ctx.Done():
+	case s.incoming <- raw:
+	}
+}
+
+// newRequest creates a request to the server carrying the configured
+// headers and, if any, a JSON body.
+func (s *clientStream) newRequest(ctx context.Context, method string, endpoint string, body []byte) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, r)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range s.options.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	}
+
+	return req, nil
+}
+
+func (s *clientStream) do(req *http.Request) (*http.Response, error) {
+	client := s.options.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return client.Do(req)
+}
+
+// checkResponse turns an unsuccessful response into an error including the
+// beginning of its body.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, MAX_ERROR_BODY_BYTES))
+
+	if detail := strings.TrimSpace(string(body)); detail != "" {
+		return fmt.Errorf("server responded with %s: %s", resp.Status, detail)
+	}
+
+	return fmt.Errorf("server responded with %s", resp.Status)
+}
+
+var _ jsonrpc2.ObjectStream = &streamableClientStream{}
+
+// streamableClientStream is the client side of the streamable HTTP
+// transport. Each message is posted to the endpoint, and the responses are
+// read from the body of the POST, either as JSON or as server-sent events.
+// Messages that aren't tied to a request are read from the session's
+// standalone stream.
+type streamableClientStream struct {
+	clientStream
+
+	endpoint string
+
+	mu              sync.Mutex
+	sessionId       string
+	protocolVersion string
+	// initializeId is the ID of the initialize request while its response is
+	// awaited, to learn the negotiated protocol version from it.
+	initializeId string
+	listening    bool
+
+	// pending queues the messages written until send posts them, waking up
+	// on wake.
+	pending   []outgoingMessage
+	pendingMu sync.Mutex
+	wake      chan struct{}
+
+	closeOnce sync.Once
+}
+
+// outgoingMessage is a message written by the client, along with its
+// encoding.
+type outgoingMessage struct {
+	message
+	data []byte
+}
+
+// NewClientStream returns a stream to the server at endpoint over the
+// streamable HTTP transport. Nothing is sent until the first message is
+// written, which should be the initialize request.
+func NewClientStream(ctx context.Context, logger *slog.Logger, endpoint string, options ClientOptions) jsonrpc2.ObjectStream {
+	s := &streamableClientStream{
+		clientStream: newClientStream(ctx, logger, options),
+		endpoint:     endpoint,
+		wake:         make(chan struct{}, 1),
+	}
+
+	go s.send()
+
+	return s
+}
+
+// WriteObject implements jsonrpc2.ObjectStream, queueing the message to be
+// posted to the endpoint in the background. It returns without waiting for
+// the server, which may only respond once it has handled a request, so that
+// long-running requests don't hold up the messages written after them, nor
+// closing the connection.
+func (s *streamableClientStream) WriteObject(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	if s.ctx.Err() != nil {
+		return io.ErrClosedPipe
+	}
+
+	s.pendingMu.Lock()
+	s.pending = append(s.pending, outgoingMessage{message: m, data: data})
+	s.pendingMu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// send posts the queued messages one after the other until the stream is
+// closed.
+func (s *streamableClientStream) send() {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wake:
+		}
+
+		for {
+			s.pendingMu.Lock()
+			if len(s.pending) == 0 {
+				s.pendingMu.Unlock()
+				break
+			}
+			out := s.pending[0]
+			s.pending = s.pending[1:]
+			s.pendingMu.Unlock()
+
+			s.post(out)
+
+			if s.ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// post sends a message and returns once it has been written, so that messages
+// reach the server in the order they were written. The initialize request is
+// waited for until the server has responded, as the following messages carry
+// the session it assigns.
+func (s *streamableClientStream) post(out outgoingMessage) {
+	req, err := s.newRequest(s.ctx, http.MethodPost, s.endpoint, out.data)
+	if err != nil {
+		s.fail(out.message, err)
+		return
+	}
+
+	req.Header.Set("Accept", CONTENT_TYPE_JSON+", "+CONTENT_TYPE_SSE)
+	s.setSessionHeaders(req)
+
+	if out.isRequest() && out.Method == "initialize" {
+		s.mu.Lock()
+		s.initializeId = out.ID.String()
+		s.mu.Unlock()
+
+		resp, err := s.do(req)
+		if resp, ok := s.accept(out.message, resp, err); ok {
+			go s.readResponse(resp)
+		}
+
+		return
+	}
+
+	wrote := make(chan struct{})
+	var wroteOnce sync.Once
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			wroteOnce.Do(func() { close(wrote) })
+		},
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		resp, err := s.do(req)
+		if resp, ok := s.accept(out.message, resp, err); ok {
+			s.readResponse(resp)
+		}
+	}()
+
+	select {
+	case <-s.ctx.Done():
+	case <-wrote:
+	case <-done:
+	}
+}
+
+// accept checks the response to a posted message, noting the session it
+// belongs to. A message that couldn't be delivered is failed.
+func (s *streamableClientStream) accept(m message, resp *http.Response, err error) (*http.Response, bool) {
+	if err != nil {
+		s.fail(m, fmt.Errorf("error posting message: %w", err))
+		return nil, false
+	}
+
+	if err := s.checkResponse(resp); err != nil {
+		resp.Body.Close()
+		s.fail(m, err)
+		return nil, false
+	}
+
+	if sessionId := resp.Header.Get(HEADER_SESSION_ID); sessionId != "" {
+		s.mu.Lock()
+		if s.sessionId == "" {
+			s.sessionId = sessionId
+		}
+		s.mu.Unlock()
+	}
+
+	if m.Method == "notifications/initialized" {
+		s.listen()
+	}
+
+	return resp, true
+}
+
+// fail reports that a message couldn't be delivered. A request is answered
+// with an error on the server's behalf so that its caller isn't left waiting.
+func (s *streamableClientStream) fail(m message, err error) {
+	if errors.Is(err, ErrSessionExpired) {
+		// The stream has ended, which fails every request in flight.
+		s.logger.Warn("error posting message", "method", m.Method, "err", err)
+		return
+	}
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	if !m.isRequest() {
+		s.logger.Warn("error posting message", "method", m.Method, "err", err)
+		return
+	}
+
+	raw, marshalErr := json.Marshal(&jsonrpc2.Response{
+		ID: *m.ID,
+		Error: &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: err.Error(),
+		},
+	})
+	if marshalErr != nil {
+		s.logger.Warn("error posting message", "method", m.Method, "err", err)
+		return
+	}
+
+	s.receive(raw)
+}
+
+// Close implements jsonrpc2.ObjectStream, ending the session on the server
+// as well.
+func (s *streamableClientStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+
+		s.mu.Lock()
+		sessionId := s.sessionId
+		s.mu.Unlock()
+
+		if sessionId == "" {
+			return
+		}
+
+		// The server is given a chance to release the session, but a server
+		// that went away isn't waited for long.
+		ctx, cancel := context.WithTimeout(context.Background(), CLIENT_CLOSE_TIMEOUT_SECONDS*time.Second)
+		defer cancel()
+
+		req, err := s.newRequest(ctx, http.MethodDelete, s.endpoint, nil)
+		if err != nil {
+			return
+		}
+
+		s.setSessionHeaders(req)
+
+		if resp, err := s.do(req); err == nil {
+			resp.Body.Close()
+		}
+	})
+
+	return nil
+}
+
+// receive queues a message sent by the server, noting the protocol version
+// negotiated by the initialize request.
+func (s *streamableClientStream) receive(raw json.RawMessage) {
+	s.recordProtocolVersion(raw)
+	s.clientStream.receive(raw)
+}
+
+func (s *streamableClientStream) recordProtocolVersion(raw json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.initializeId == "" {
+		return
+	}
+
+	var response struct {
+		ID     *jsonrpc2.ID          `json:"id"`
+		Result *mcp.InitializeResult `json:"result"`
+	}
+
+	if err := json.Unmarshal(raw, &response); err != nil || response.ID == nil || response.ID.String() != s.initializeId {
+		return
+	}
+
+	if response.Result != nil {
+		s.protocolVersion = response.Result.ProtocolVersion
+	}
+
+	s.initializeId = ""
+}
+
+// setSessionHeaders identifies the session once the server has assigned one.
+func (s *streamableClientStream) setSessionHeaders(req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessionId != "" {
+		req.Header.Set(HEADER_SESSION_ID, s.sessionId)
+	}
+
+	if s.protocolVersion != "" {
+		req.Header.Set(HEADER_PROTOCOL_VERSION, s.protocolVersion)
+	}
+}
+
+// checkResponse turns an unsuccessful response into an error, ending the
+// stream when the server reports that the session has expired.
+func (s *streamableClientStream) checkResponse(resp *http.Response) error {
+	s.mu.Lock()
+	hasSession := s.sessionId != ""
+	s.mu.Unlock()
+
+	if resp.StatusCode == http.StatusNotFound && hasSession {
+		s.cancel()
+		return ErrSessionExpired
+	}
+
+	return checkResponse(resp)
+}
+
+// readResponse queues the messages carried by the response to a POST. Bodies
+// that are neither JSON nor an event stream, such as that of a 202 Accepted,
+// carry none.
+func (s *streamableClientStream) readResponse(resp *http.Response) {
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case CONTENT_TYPE_SSE:
+		err := readEvents(resp.Body, func(event, _ string, data string) {
+			if isMessageEvent(event) {
+				s.receive(json.RawMessage(data))
+			}
+		})
+		if err != nil && s.ctx.Err() == nil {
+			s.logger.Warn("error reading response stream", "err", err)
+		}
+
+	case CONTENT_TYPE_JSON:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.logger.Warn("error reading response", "err", err)
+			}
+			return
+		}
+
+		raws, _, err := parseMessages(body)
+		if err != nil {
+			s.logger.Warn("error parsing response", "err", err)
+			return
+		}
+
+		for _, raw := range raws {
+			s.receive(raw)
+		}
+	}
+}
+
+// listen opens the standalone stream of the session in the background, once,
+// for the server to send messages that aren't tied to a request. It is
+// reopened where it left off when interrupted. Servers that don't offer one
+// respond with 405 Method Not Allowed.
+func (s *streamableClientStream) listen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listening {
+		return
+	}
+	s.listening = true
+
+	go func() {
+		var lastEventId string
+
+		for {
+			resumable, err := s.listenOnce(&lastEventId)
+			if err != nil && s.ctx.Err() == nil {
+				s.logger.Warn("error listening for server messages", "err", err)
+			}
+
+			if !resumable {
+				return
+			}
+
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(CLIENT_LISTEN_RETRY_INTERVAL):
+			}
+		}
+	}()
+}
+
+// listenOnce reads the standalone stream until it ends, reporting whether it
+// is worth reopening.
+func (s *streamableClientStream) listenOnce(lastEventId *string) (bool, error) {
+	req, err := s.newRequest(s.ctx, http.MethodGet, s.endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Accept", CONTENT_TYPE_SSE)
+	s.setSessionHeaders(req)
+
+	if *lastEventId != "" {
+		req.Header.Set(HEADER_LAST_EVENT_ID, *lastEventId)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return s.ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		return false, nil
+	}
+
+	if err := s.checkResponse(resp); err != nil {
+		return false, err
+	}
+
+	err = readEvents(resp.Body, func(event, id string, data string) {
+		*lastEventId = id
+
+		if isMessageEvent(event) {
+			s.receive(json.RawMessage(data))
+		}
+	})
+
+	return s.ctx.Err() == nil, err
+}
+
+var _ jsonrpc2.ObjectStream = &legacyClientStream{}
+
+// legacyClientStream is the client side of the HTTP+SSE transport of the
+// 2024-11-05 revision. Messages are posted to the endpoint announced by the
+// server while everything the server sends is read from a single event
+// stream.
+type legacyClientStream struct {
+	clientStream
+
+	endpoint string
+}
+
+// DialLegacySSE connects to the server at sseURL over the HTTP+SSE transport,
+// waiting for the server to announce where messages are to be posted. The
+// stream ends along with the server's event stream.
+func DialLegacySSE(ctx context.Context, logger *slog.Logger, sseURL string, options ClientOptions) (jsonrpc2.ObjectStream, error) {
+	s := &legacyClientStream{
+		clientStream: newClientStream(ctx, logger, options),
+	}
+
+	base, err := url.Parse(sseURL)
+	if err != nil {
+		s.cancel()
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	req, err := s.newRequest(s.ctx, http.MethodGet, sseURL, nil)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+
+	req.Header.Set("Accept", CONTENT_TYPE_SSE)
+
+	resp, err := s.do(req)
+	if err != nil {
+		s.cancel()
+		return nil, fmt.Errorf("error opening event stream: %w", err)
+	}
+
+	if err := checkResponse(resp); err != nil {
+		resp.Body.Close()
+		s.cancel()
+		return nil, err
+	}
+
+	endpoints := make(chan string, 1)
+
+	go func() {
+		defer s.cancel()
+		defer resp.Body.Close()
+
+		err := readEvents(resp.Body, func(event, _ string, data string) {
+			switch {
+			case event == EVENT_ENDPOINT:
+				select {
+				case endpoints <- data:
+				default:
+				}
+			case isMessageEvent(event):
+				s.receive(json.RawMessage(data))
+			}
+		})
+		if err != nil && s.ctx.Err() == nil {
+			logger.Warn("error reading event stream", "err", err)
+		}
+	}()
+
+	var endpoint string
+
+	select {
+	case <-s.ctx.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("event stream ended before the message endpoint was announced")
+	case endpoint = <-endpoints:
+	}
+
+	u, err := base.Parse(endpoint)
+	if err != nil {
+		s.cancel()
+		return nil, fmt.Errorf("invalid message endpoint %q: %w", endpoint, err)
+	}
+
+	// The headers, which may carry credentials, must not be sent elsewhere.
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		s.cancel()
+		return nil, fmt.Errorf("message endpoint %q isn't on the same origin as the event stream", endpoint)
+	}
+
+	s.endpoint = u.String()
+
+	return s, nil
+}
+
+// WriteObject implements jsonrpc2.ObjectStream, posting the message to the
+// endpoint announced by the server.
+func (s *legacyClientStream) WriteObject(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	if s.ctx.Err() != nil {
+		return io.ErrClosedPipe
+	}
+
+	req, err := s.newRequest(s.ctx, http.MethodPost, s.endpoint, data)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("error posting message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp)
+}
+
+// Close implements jsonrpc2.ObjectStream, closing the event stream and with
+// it the session.
+func (s *legacyClientStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+func isMessageEvent(event string) bool {
+	return event == "" || event == "message"
+}
+
+// readEvents calls fn with each event read from a stream of server-sent
+// events, along with the last event ID seen, until the stream ends.
+func readEvents(r io.Reader, fn func(event, id string, data string)) error {
+	br := bufio.NewReader(r)
+
+	var (
+		event, id string
+		data      strings.Builder
+		hasData   bool
+	)
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if hasData {
+				fn(event, id, data.String())
+			}
+
+			event = ""
+			data.Reset()
+			hasData = false
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			id = value
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package httptransport
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+type readEvent struct {
+	event string
+	id    string
+	data  string
+}
+
+func TestReadEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   []readEvent
+	}{
+		{
+			name:   "empty",
+			stream: "",
+		},
+		{
+			name:   "single event",
+			stream: "data: {\"jsonrpc\":\"2.0\"}\n\n",
+			want:   []readEvent{{data: `{"jsonrpc":"2.0"}`}},
+		},
+		{
+			name:   "named events with ids",
+			stream: "event: endpoint\ndata: /message?sessionId=1\n\nevent: message\nid: 0-1\ndata: {}\n\n",
+			want: []readEvent{
+				{event: "endpoint", data: "/message?sessionId=1"},
+				{event: "message", id: "0-1", data: "{}"},
+			},
+		},
+		{
+			name:   "id is kept until replaced",
+			stream: "id: 0-1\ndata: a\n\ndata: b\n\nid: 0-2\ndata: c\n\n",
+			want: []readEvent{
+				{id: "0-1", data: "a"},
+				{id: "0-1", data: "b"},
+				{id: "0-2", data: "c"},
+			},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: line 1\ndata: line 2\ndata:line 3\n\n",
+			want:   []readEvent{{data: "line 1\nline 2\nline 3"}},
+		},
+		{
+			name:   "carriage returns",
+			stream: "id: 1-1\r\ndata: {}\r\n\r\n",
+			want:   []readEvent{{id: "1-1", data: "{}"}},
+		},
+		{
+			name:   "comments and unknown fields are ignored",
+			stream: ": keep-alive\nretry: 1000\ndata: {}\n\n",
+			want:   []readEvent{{data: "{}"}},
+		},
+		{
+			name:   "events without data are not dispatched",
+			stream: "event: ping\n\nid: 0-3\n\ndata: {}\n\n",
+			want:   []readEvent{{id: "0-3", data: "{}"}},
+		},
+		{
+			name:   "unterminated event is dropped",
+			stream: "data: a\n\ndata: b\n",
+			want:   []readEvent{{data: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []readEvent
+			err := readEvents(strings.NewReader(tt.stream), func(event, id, data string) {
+				got = append(got, readEvent{event: event, id: id, data: data})
+			})
+			if err != nil {
+				t.Fatalf("readEvents() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readEvents() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEventsError(t *testing.T) {
+	errBroken := errors.New("connection reset")
+
+	var got []readEvent
+	err := readEvents(io.MultiReader(strings.NewReader("data: a\n\ndata: b\n"), iotest.ErrReader(errBroken)), func(event, id, data string) {
+		got = append(got, readEvent{event: event, id: id, data: data})
+	})
+	if !errors.Is(err, errBroken) {
+		t.Errorf("readEvents() error = %v, want %v", err, errBroken)
+	}
+
+	if want := []readEvent{{data: "a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readEvents() = %+v, want %+v", got, want)
+	}
+}
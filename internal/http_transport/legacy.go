@@ -0,0 +1,102 @@
+package httptransport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// LEGACY_SESSION_ID_PARAM is the query parameter of the message endpoint
+	// naming the session of a client of the HTTP+SSE transport.
+	LEGACY_SESSION_ID_PARAM = "sessionId"
+
+	EVENT_ENDPOINT = "endpoint"
+)
+
+// LegacySSEHandler serves the event stream of the HTTP+SSE transport of the
+// 2024-11-05 revision. Each GET starts a session that lasts as long as the
+// stream, and whose first event tells the client where to post its messages:
+// messagePath, which must be served by LegacyMessageHandler.
+func (s *Server) LegacySSEHandler(messagePath string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAllowedOrigin(r.Header.Get("Origin")) {
+			writeError(w, http.StatusForbidden, jsonrpc2.CodeInvalidRequest, "origin not allowed")
+			return
+		}
+
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeError(w, http.StatusMethodNotAllowed, jsonrpc2.CodeInvalidRequest, "method not allowed")
+			return
+		}
+
+		sess := s.startSession(true)
+		defer s.endSession(sess)
+
+		rc, err := openEventStream(w)
+		if err != nil {
+			sess.logger.Warn("error flushing event stream", "err", err)
+			return
+		}
+
+		endpoint := messagePath + "?" + url.Values{LEGACY_SESSION_ID_PARAM: {sess.id}}.Encode()
+
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", EVENT_ENDPOINT, endpoint); err != nil {
+			return
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		s.pumpEvents(w, rc, r, sess, sess.standalone, 0)
+	})
+}
+
+// LegacyMessageHandler accepts the messages posted by the clients of the
+// HTTP+SSE transport. Their responses are sent on the session's event stream.
+func (s *Server) LegacyMessageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAllowedOrigin(r.Header.Get("Origin")) {
+			writeError(w, http.StatusForbidden, jsonrpc2.CodeInvalidRequest, "origin not allowed")
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeError(w, http.StatusMethodNotAllowed, jsonrpc2.CodeInvalidRequest, "method not allowed")
+			return
+		}
+
+		sess, ok := s.getSession(r.URL.Query().Get(LEGACY_SESSION_ID_PARAM))
+		if !ok || !sess.legacy {
+			writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session not found")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_REQUEST_BODY_BYTES))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, jsonrpc2.CodeParseError, "error reading request body")
+			return
+		}
+
+		raws, _, err := parseMessages(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, jsonrpc2.CodeParseError, err.Error())
+			return
+		}
+
+		for _, raw := range raws {
+			if err := sess.deliver(r.Context(), raw); err != nil {
+				writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session closed")
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
@@ -0,0 +1,595 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp/internal/mcp"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	HEADER_SESSION_ID       = "Mcp-Session-Id"
+	HEADER_PROTOCOL_VERSION = "Mcp-Protocol-Version"
+	HEADER_LAST_EVENT_ID    = "Last-Event-ID"
+
+	// DEFAULT_SESSION_IDLE_TIMEOUT_MINUTES is how long a session is kept
+	// without any client activity before being closed.
+	DEFAULT_SESSION_IDLE_TIMEOUT_MINUTES = 30
+
+	// MAX_REQUEST_BODY_BYTES bounds the size of the messages posted by
+	// clients.
+	MAX_REQUEST_BODY_BYTES = 4 << 20
+
+	CONTENT_TYPE_JSON = "application/json"
+	CONTENT_TYPE_SSE  = "text/event-stream"
+)
+
+// SessionHandler serves an MCP session, reading the client's messages from
+// stream and writing its own to it. The session ends when it returns.
+type SessionHandler func(ctx context.Context, stream jsonrpc2.ObjectStream) error
+
+type ServerOptions struct {
+	// AllowedOrigins lists the origins, besides those of the local host,
+	// allowed to connect. Browsers send an Origin header that must be checked
+	// to protect local servers from DNS rebinding attacks.
+	AllowedOrigins []string
+
+	// SessionIdleTimeout is how long a session is kept without any client
+	// activity. Defaults to DEFAULT_SESSION_IDLE_TIMEOUT_MINUTES.
+	SessionIdleTimeout time.Duration
+}
+
+// Server implements the streamable HTTP transport of the Model Context
+// Protocol, running handler for every session initialized by a client. It
+// also serves the clients of the older HTTP+SSE transport through
+// LegacySSEHandler and LegacyMessageHandler.
+type Server struct {
+	logger  *slog.Logger
+	handler SessionHandler
+	options ServerOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+var _ http.Handler = &Server{}
+
+func NewServer(ctx context.Context, logger *slog.Logger, handler SessionHandler, options ServerOptions) *Server {
+	if options.SessionIdleTimeout <= 0 {
+		options.SessionIdleTimeout = DEFAULT_SESSION_IDLE_TIMEOUT_MINUTES * time.Minute
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Server{
+		logger:   logger,
+		handler:  handler,
+		options:  options,
+		ctx:      ctx,
+		cancel:   cancel,
+		sessions: make(map[string]*session),
+	}
+
+	go s.expireSessions()
+
+	return s
+}
+
+// Close ends every session.
+func (s *Server) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*session)
+	s.mu.Unlock()
+
+	for _, sess := range sessions {
+		sess.Close()
+	}
+
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.isAllowedOrigin(r.Header.Get("Origin")) {
+		writeError(w, http.StatusForbidden, jsonrpc2.CodeInvalidRequest, "origin not allowed")
+		return
+	}
+
+	if version := r.Header.Get(HEADER_PROTOCOL_VERSION); version != "" && !mcp.IsSupportedProtocolVersion(version) {
+		writeError(w, http.StatusBadRequest, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("unsupported protocol version %q", version))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		s.handlePost(w, r)
+	case http.MethodGet:
+		s.handleGet(w, r)
+	case http.MethodDelete:
+		s.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, jsonrpc2.CodeInvalidRequest, "method not allowed")
+	}
+}
+
+// handlePost handles the messages posted by a client. Requests are answered
+// either with a single JSON body once every response is known or with a
+// stream of events, which also carries the requests and notifications the
+// session handler sends in the meantime.
+func (s *Server) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_REQUEST_BODY_BYTES))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, jsonrpc2.CodeParseError, "error reading request body")
+		return
+	}
+
+	raws, batch, err := parseMessages(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, jsonrpc2.CodeParseError, err.Error())
+		return
+	}
+
+	messages := make([]message, len(raws))
+	var requestIds []string
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &messages[i]); err != nil || (messages[i].Method == "" && messages[i].ID == nil) {
+			writeError(w, http.StatusBadRequest, jsonrpc2.CodeInvalidRequest, "invalid JSON-RPC message")
+			return
+		}
+
+		if messages[i].isRequest() {
+			requestIds = append(requestIds, messages[i].ID.String())
+		}
+	}
+
+	// Check that responses can be sent before starting a session that would
+	// otherwise be left behind.
+	sse := accepts(r, CONTENT_TYPE_SSE)
+	if len(requestIds) > 0 && !sse && !accepts(r, CONTENT_TYPE_JSON) {
+		writeError(w, http.StatusNotAcceptable, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("client must accept %s or %s", CONTENT_TYPE_JSON, CONTENT_TYPE_SSE))
+		return
+	}
+
+	var sess *session
+	if id := r.Header.Get(HEADER_SESSION_ID); id != "" {
+		var ok bool
+		if sess, ok = s.getSession(id); !ok || sess.legacy {
+			writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session not found")
+			return
+		}
+	} else {
+		if len(messages) != 1 || messages[0].Method != "initialize" || !messages[0].isRequest() {
+			writeError(w, http.StatusBadRequest, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("missing %s header", HEADER_SESSION_ID))
+			return
+		}
+
+		sess = s.startSession(false)
+	}
+
+	w.Header().Set(HEADER_SESSION_ID, sess.id)
+
+	if len(requestIds) == 0 {
+		for _, raw := range raws {
+			if err := sess.deliver(r.Context(), raw); err != nil {
+				writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session closed")
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	st := sess.openStream(requestIds, sse)
+
+	for _, raw := range raws {
+		if err := sess.deliver(r.Context(), raw); err != nil {
+			writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session closed")
+			return
+		}
+	}
+
+	if sse {
+		s.streamEvents(w, r, sess, st, 0)
+		return
+	}
+
+	s.writeResponses(w, r, sess, st, batch)
+}
+
+// handleGet opens a stream of events on which the session handler can send
+// requests and notifications to the client at any time. A client passing the
+// ID of the last event it received resumes the stream that event belongs to
+// instead.
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !accepts(r, CONTENT_TYPE_SSE) {
+		writeError(w, http.StatusNotAcceptable, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("client must accept %s", CONTENT_TYPE_SSE))
+		return
+	}
+
+	sess, ok := s.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	if lastEventId := r.Header.Get(HEADER_LAST_EVENT_ID); lastEventId != "" {
+		st, after, ok := parseEventId(sess, lastEventId)
+		if !ok {
+			writeError(w, http.StatusBadRequest, jsonrpc2.CodeInvalidRequest, "unknown event id")
+			return
+		}
+
+		s.streamEvents(w, r, sess, st, after)
+		return
+	}
+
+	sess.mu.Lock()
+	after := sess.standalone.delivered
+	sess.mu.Unlock()
+
+	s.streamEvents(w, r, sess, sess.standalone, after)
+}
+
+// handleDelete ends a session at the client's request.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess, ok := s.requireSession(w, r)
+	if !ok {
+		return
+	}
+
+	s.endSession(sess)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// streamEvents sends the events of st following the after sequence number as
+// server-sent events, until the stream ends, the client goes away or another
+// request resumes the stream.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, sess *session, st *eventStream, after int) {
+	rc, err := openEventStream(w)
+	if err != nil {
+		sess.logger.Warn("error flushing event stream", "err", err)
+		return
+	}
+
+	s.pumpEvents(w, rc, r, sess, st, after)
+}
+
+// openEventStream sends the headers of a response made of server-sent events.
+func openEventStream(w http.ResponseWriter) (*http.ResponseController, error) {
+	w.Header().Set("Content-Type", CONTENT_TYPE_SSE)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	return rc, rc.Flush()
+}
+
+// pumpEvents writes the events of st to an event stream already opened.
+func (s *Server) pumpEvents(w http.ResponseWriter, rc *http.ResponseController, r *http.Request, sess *session, st *eventStream, after int) {
+	generation := sess.attach(st)
+	defer sess.detach(st, generation)
+
+	for {
+		sess.mu.Lock()
+		events := st.eventsAfter(after)
+		changed := st.changed
+		done := st.done
+		superseded := st.attached != generation
+		sess.mu.Unlock()
+
+		if superseded {
+			return
+		}
+
+		for _, ev := range events {
+			if sess.legacy {
+				if _, err := fmt.Fprint(w, "event: message\n"); err != nil {
+					return
+				}
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %s\ndata: %s\n\n", st.eventId(ev.seq), ev.data); err != nil {
+				return
+			}
+			after = ev.seq
+		}
+
+		if len(events) > 0 {
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+			sess.mu.Lock()
+			st.delivered = max(st.delivered, after)
+			sess.mu.Unlock()
+
+			continue
+		}
+
+		if done {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// writeResponses waits for every response of st and sends them as a single
+// JSON body, an array when the client posted a batch. The request reads the
+// stream like an event stream would, so that the session isn't considered
+// idle while the responses are pending.
+func (s *Server) writeResponses(w http.ResponseWriter, r *http.Request, sess *session, st *eventStream, batch bool) {
+	generation := sess.attach(st)
+	defer sess.detach(st, generation)
+
+	for {
+		sess.mu.Lock()
+		changed := st.changed
+		done := st.done
+		sess.mu.Unlock()
+
+		if done {
+			break
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.ctx.Done():
+			writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session closed")
+			return
+		case <-changed:
+		}
+	}
+
+	sess.mu.Lock()
+	events := st.eventsAfter(0)
+	st.delivered = st.seq
+	delete(sess.streams, st.id)
+	sess.mu.Unlock()
+
+	var body []byte
+	if batch {
+		responses := make([]json.RawMessage, len(events))
+		for i, ev := range events {
+			responses[i] = ev.data
+		}
+
+		var err error
+		if body, err = json.Marshal(responses); err != nil {
+			writeError(w, http.StatusInternalServerError, jsonrpc2.CodeInternalError, err.Error())
+			return
+		}
+	} else if len(events) > 0 {
+		body = events[0].data
+	}
+
+	w.Header().Set("Content-Type", CONTENT_TYPE_JSON)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// startSession creates a session and runs the session handler on it in the
+// background.
+func (s *Server) startSession(legacy bool) *session {
+	sess := newSession(s.ctx, s.logger, legacy)
+
+	s.mu.Lock()
+	s.sessions[sess.id] = sess
+	s.mu.Unlock()
+
+	sess.logger.Info("session started")
+
+	go func() {
+		defer s.endSession(sess)
+
+		if err := s.handler(sess.ctx, sess); err != nil && !errors.Is(err, context.Canceled) {
+			sess.logger.Warn("session handler finished with an error", "err", err)
+		}
+	}()
+
+	return sess
+}
+
+func (s *Server) endSession(sess *session) {
+	s.mu.Lock()
+	_, ok := s.sessions[sess.id]
+	delete(s.sessions, sess.id)
+	s.mu.Unlock()
+
+	sess.Close()
+
+	if ok {
+		sess.logger.Info("session ended")
+	}
+}
+
+func (s *Server) getSession(id string) (*session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	return sess, ok
+}
+
+// requireSession looks up the session named by the request, answering the
+// request with an error when there is none.
+func (s *Server) requireSession(w http.ResponseWriter, r *http.Request) (*session, bool) {
+	id := r.Header.Get(HEADER_SESSION_ID)
+	if id == "" {
+		writeError(w, http.StatusBadRequest, jsonrpc2.CodeInvalidRequest, fmt.Sprintf("missing %s header", HEADER_SESSION_ID))
+		return nil, false
+	}
+
+	sess, ok := s.getSession(id)
+	if !ok || sess.legacy {
+		writeError(w, http.StatusNotFound, jsonrpc2.CodeInvalidRequest, "session not found")
+		return nil, false
+	}
+
+	return sess, true
+}
+
+// expireSessions periodically ends the sessions that have been idle for
+// longer than the configured timeout.
+func (s *Server) expireSessions() {
+	ticker := time.NewTicker(min(time.Minute, s.options.SessionIdleTimeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			sessions := make([]*session, 0, len(s.sessions))
+			for _, sess := range s.sessions {
+				sessions = append(sessions, sess)
+			}
+			s.mu.Unlock()
+
+			for _, sess := range sessions {
+				if idle := sess.idleSince(); !idle.IsZero() && now.Sub(idle) > s.options.SessionIdleTimeout {
+					sess.logger.Info("closing idle session")
+					s.endSession(sess)
+				}
+			}
+		}
+	}
+}
+
+// isAllowedOrigin reports whether a request with the given Origin header may
+// be served. Requests without one don't come from a browser.
+func (s *Server) isAllowedOrigin(origin string) bool {
+	if origin == "" || slices.Contains(s.options.AllowedOrigins, origin) {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseMessages splits a request body into its JSON-RPC messages, reporting
+// whether they were sent as a batch.
+func parseMessages(body []byte) ([]json.RawMessage, bool, error) {
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var raws []json.RawMessage
+		if err := json.Unmarshal(body, &raws); err != nil {
+			return nil, false, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		if len(raws) == 0 {
+			return nil, false, fmt.Errorf("empty batch")
+		}
+
+		return raws, true, nil
+	}
+
+	if !json.Valid(body) {
+		return nil, false, fmt.Errorf("invalid JSON")
+	}
+
+	return []json.RawMessage{body}, false, nil
+}
+
+// parseEventId finds the stream an event ID of the form
+// "<stream>-<sequence>" belongs to.
+func parseEventId(sess *session, eventId string) (*eventStream, int, bool) {
+	streamPart, seqPart, ok := strings.Cut(eventId, "-")
+	if !ok {
+		return nil, 0, false
+	}
+
+	streamId, err := strconv.Atoi(streamPart)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	seq, err := strconv.Atoi(seqPart)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	st, ok := sess.getStream(streamId)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return st, seq, true
+}
+
+// accepts reports whether the request's Accept header includes the media
+// type, either explicitly or through a wildcard.
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			t, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			if t == mediaType || t == "*/*" || t == strings.SplitN(mediaType, "/", 2)[0]+"/*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, code int64, message string) {
+	w.Header().Set("Content-Type", CONTENT_TYPE_JSON)
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error": &jsonrpc2.Error{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
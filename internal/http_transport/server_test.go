@@ -0,0 +1,247 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// SLOW_REQUEST_DELAY is how long the test server takes to answer a request
+// with the "slow" method.
+const SLOW_REQUEST_DELAY = 300 * time.Millisecond
+
+// newTestServer serves sessions that answer every request with an empty
+// result and send the client whatever is written to notify.
+func newTestServer(t *testing.T, notify <-chan string, options ServerOptions) *httptest.Server {
+	t.Helper()
+
+	s := NewServer(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), func(ctx context.Context, stream jsonrpc2.ObjectStream) error {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case method := <-notify:
+					_ = stream.WriteObject(map[string]any{"jsonrpc": "2.0", "method": method})
+				}
+			}
+		}()
+
+		for {
+			var m message
+			if err := stream.ReadObject(&m); err != nil {
+				return nil
+			}
+
+			if !m.isRequest() {
+				continue
+			}
+
+			response := map[string]any{"jsonrpc": "2.0", "id": m.ID, "result": map[string]any{}}
+
+			if m.Method == "slow" {
+				time.AfterFunc(SLOW_REQUEST_DELAY, func() {
+					_ = stream.WriteObject(response)
+				})
+				continue
+			}
+
+			if err := stream.WriteObject(response); err != nil {
+				return err
+			}
+		}
+	}, options)
+
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+
+	return ts
+}
+
+// initializeSession starts a session on the test server and returns its ID.
+func initializeSession(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	req.Header.Set("Accept", CONTENT_TYPE_JSON)
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("initialize status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	return resp.Header.Get(HEADER_SESSION_ID)
+}
+
+// openStandaloneStream opens the session's standalone stream, resuming after
+// lastEventId when set, and returns the events read from it.
+func openStandaloneStream(t *testing.T, ctx context.Context, ts *httptest.Server, sessionId string, lastEventId string) (*http.Response, <-chan readEvent) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", CONTENT_TYPE_SSE)
+	req.Header.Set(HEADER_SESSION_ID, sessionId)
+	if lastEventId != "" {
+		req.Header.Set(HEADER_LAST_EVENT_ID, lastEventId)
+	}
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	events := make(chan readEvent, 16)
+	if resp.StatusCode == http.StatusOK {
+		go func() {
+			defer close(events)
+
+			_ = readEvents(resp.Body, func(event, id, data string) {
+				events <- readEvent{event: event, id: id, data: data}
+			})
+		}()
+	} else {
+		close(events)
+	}
+
+	return resp, events
+}
+
+func nextEvent(t *testing.T, events <-chan readEvent) readEvent {
+	t.Helper()
+
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream ended")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	return readEvent{}
+}
+
+func eventMethod(t *testing.T, ev readEvent) string {
+	t.Helper()
+
+	var m message
+	if err := json.Unmarshal([]byte(ev.data), &m); err != nil {
+		t.Fatalf("event data %q is not a message: %v", ev.data, err)
+	}
+
+	return m.Method
+}
+
+func TestResumeStandaloneStream(t *testing.T) {
+	notify := make(chan string)
+	ts := newTestServer(t, notify, ServerOptions{})
+	sessionId := initializeSession(t, ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, events := openStandaloneStream(t, ctx, ts, sessionId, "")
+
+	notify <- "notifications/first"
+
+	first := nextEvent(t, events)
+	if method := eventMethod(t, first); method != "notifications/first" {
+		t.Fatalf("first event method = %q, want %q", method, "notifications/first")
+	}
+
+	// The connection drops and the session handler keeps sending while the
+	// client is away.
+	cancel()
+	for range events {
+		// Wait for the stream to be closed.
+	}
+
+	notify <- "notifications/second"
+	notify <- "notifications/third"
+
+	resp, events := openStandaloneStream(t, context.Background(), ts, sessionId, first.id)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("resume status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	for _, want := range []string{"notifications/second", "notifications/third"} {
+		if method := eventMethod(t, nextEvent(t, events)); method != want {
+			t.Errorf("resumed event method = %q, want %q", method, want)
+		}
+	}
+}
+
+func TestResumeUnknownEventId(t *testing.T) {
+	ts := newTestServer(t, nil, ServerOptions{})
+	sessionId := initializeSession(t, ts)
+
+	tests := []string{
+		"0",
+		"x-1",
+		"0-x",
+		"42-1",
+	}
+
+	for _, lastEventId := range tests {
+		t.Run(lastEventId, func(t *testing.T) {
+			resp, _ := openStandaloneStream(t, context.Background(), ts, sessionId, lastEventId)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestSessionNotExpiredWhileWaitingForResponse(t *testing.T) {
+	ts := newTestServer(t, nil, ServerOptions{
+		SessionIdleTimeout: SLOW_REQUEST_DELAY / 6,
+	})
+	sessionId := initializeSession(t, ts)
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"slow"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", CONTENT_TYPE_JSON)
+	req.Header.Set("Accept", CONTENT_TYPE_JSON)
+	req.Header.Set(HEADER_SESSION_ID, sessionId)
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("status = %d (%s), want %d", resp.StatusCode, body, http.StatusOK)
+	}
+
+	var m message
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil || !m.isResponse() {
+		t.Errorf("body is not a response: %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// MAX_STREAM_EVENTS is the number of events retained per stream for
+	// clients resuming it after losing their connection.
+	MAX_STREAM_EVENTS = 1000
+
+	// MAX_RETAINED_STREAMS bounds the number of finished request streams kept
+	// around for clients that never came back to read them.
+	MAX_RETAINED_STREAMS = 100
+)
+
+// message captures what the transport needs to know about a JSON-RPC
+// message to route it.
+type message struct {
+	ID     *jsonrpc2.ID `json:"id"`
+	Method string       `json:"method"`
+}
+
+func (m message) isRequest() bool {
+	return m.Method != "" && m.ID != nil
+}
+
+func (m message) isResponse() bool {
+	return m.Method == "" && m.ID != nil
+}
+
+type sseEvent struct {
+	seq  int
+	data json.RawMessage
+}
+
+// eventStream is an ordered, resumable sequence of messages sent to the
+// client. Each POST carrying requests gets its own stream, which ends once
+// every request has been answered. The session's standalone stream carries
+// the messages that aren't tied to a request and never ends.
+type eventStream struct {
+	id     int
+	events []sseEvent
+	seq    int
+
+	// remaining is the number of responses the stream is still waiting on.
+	remaining int
+	done      bool
+	// sse is set when the client reads the stream as server-sent events, and
+	// so can receive messages other than responses on it.
+	sse bool
+
+	// attached is the generation of the HTTP response currently reading the
+	// stream, zero when none is.
+	attached   int
+	generation int
+	delivered  int
+
+	// changed is closed and replaced whenever the stream changes.
+	changed chan struct{}
+}
+
+func newEventStream(id int, remaining int, sse bool) *eventStream {
+	return &eventStream{
+		id:        id,
+		remaining: remaining,
+		sse:       sse,
+		changed:   make(chan struct{}),
+	}
+}
+
+func (st *eventStream) append(data json.RawMessage) {
+	st.seq++
+	st.events = append(st.events, sseEvent{seq: st.seq, data: data})
+
+	if len(st.events) > MAX_STREAM_EVENTS {
+		st.events = st.events[len(st.events)-MAX_STREAM_EVENTS:]
+	}
+
+	st.notify()
+}
+
+func (st *eventStream) notify() {
+	close(st.changed)
+	st.changed = make(chan struct{})
+}
+
+func (st *eventStream) eventsAfter(seq int) []sseEvent {
+	for i, ev := range st.events {
+		if ev.seq > seq {
+			return append([]sseEvent(nil), st.events[i:]...)
+		}
+	}
+
+	return nil
+}
+
+func (st *eventStream) eventId(seq int) string {
+	return fmt.Sprintf("%d-%d", st.id, seq)
+}
+
+// session is a client's MCP session. It is the jsonrpc2.ObjectStream through
+// which the session handler talks to the client.
+type session struct {
+	id     string
+	logger *slog.Logger
+
+	// legacy is set for the sessions of clients using the HTTP+SSE transport
+	// of the 2024-11-05 revision, which receive every message, responses
+	// included, on their standalone stream.
+	legacy bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	incoming chan json.RawMessage
+
+	mu           sync.Mutex
+	nextStreamId int
+	standalone   *eventStream
+	streams      map[int]*eventStream
+	pending      map[string]*eventStream
+	attached     int
+	lastActive   time.Time
+}
+
+var _ jsonrpc2.ObjectStream = &session{}
+
+func newSession(ctx context.Context, logger *slog.Logger, legacy bool) *session {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &session{
+		id:         uuid.NewString(),
+		legacy:     legacy,
+		ctx:        ctx,
+		cancel:     cancel,
+		incoming:   make(chan json.RawMessage, 16),
+		standalone: newEventStream(0, 0, true),
+		streams:    make(map[int]*eventStream),
+		pending:    make(map[string]*eventStream),
+		lastActive: time.Now(),
+	}
+
+	s.logger = logger.With("session", s.id)
+	s.streams[0] = s.standalone
+	s.nextStreamId = 1
+
+	return s
+}
+
+// ReadObject implements jsonrpc2.ObjectStream, yielding the messages posted by
+// the client.
+func (s *session) ReadObject(v interface{}) error {
+	select {
+	case <-s.ctx.Done():
+		return io.EOF
+	case raw := <-s.incoming:
+		return json.Unmarshal(raw, v)
+	}
+}
+
+// WriteObject implements jsonrpc2.ObjectStream, routing responses to the
+// stream of the POST that carried the request and anything else to the
+// client's standalone stream, or to one of its request streams when it isn't
+// listening on the former.
+func (s *session) WriteObject(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	var m message
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx.Err() != nil {
+		return io.ErrClosedPipe
+	}
+
+	if m.isResponse() && !s.legacy {
+		key := m.ID.String()
+
+		st, ok := s.pending[key]
+		if !ok {
+			s.logger.Warn("dropping response to unknown request", "id", key)
+			return nil
+		}
+
+		delete(s.pending, key)
+
+		st.append(data)
+		st.remaining--
+		if st.remaining <= 0 {
+			st.done = true
+			st.notify()
+		}
+
+		return nil
+	}
+
+	target := s.standalone
+	if target.attached == 0 {
+		if st := s.latestAttachedRequestStream(); st != nil {
+			target = st
+		}
+	}
+
+	target.append(data)
+
+	return nil
+}
+
+// Close implements jsonrpc2.ObjectStream, ending the session.
+func (s *session) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.streams {
+		st.notify()
+	}
+
+	return nil
+}
+
+func (s *session) latestAttachedRequestStream() *eventStream {
+	var latest *eventStream
+
+	for _, st := range s.streams {
+		if st == s.standalone || !st.sse || st.done || st.attached == 0 {
+			continue
+		}
+
+		if latest == nil || st.id > latest.id {
+			latest = st
+		}
+	}
+
+	return latest
+}
+
+// openStream creates the stream on which the responses to the given requests
+// will be sent.
+func (s *session) openStream(requestIds []string, sse bool) *eventStream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastActive = time.Now()
+
+	st := newEventStream(s.nextStreamId, len(requestIds), sse)
+	s.nextStreamId++
+
+	s.streams[st.id] = st
+	for _, id := range requestIds {
+		s.pending[id] = st
+	}
+
+	s.pruneStreams()
+
+	return st
+}
+
+// pruneStreams forgets the oldest finished streams beyond
+// MAX_RETAINED_STREAMS.
+func (s *session) pruneStreams() {
+	for len(s.streams) > MAX_RETAINED_STREAMS+1 {
+		oldest := -1
+		for id, st := range s.streams {
+			if st.done && st.attached == 0 && (oldest == -1 || id < oldest) {
+				oldest = id
+			}
+		}
+
+		if oldest == -1 {
+			return
+		}
+
+		delete(s.streams, oldest)
+	}
+}
+
+// deliver hands a message posted by the client to the session handler.
+func (s *session) deliver(ctx context.Context, raw json.RawMessage) error {
+	s.mu.Lock()
+	s.lastActive = time.Now()
+	s.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return io.ErrClosedPipe
+	case s.incoming <- raw:
+		return nil
+	}
+}
+
+// attach marks the stream as read by a new HTTP response, detaching any
+// previous one, and returns the response's generation.
+func (s *session) attach(st *eventStream) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st.generation++
+	st.attached = st.generation
+	s.attached++
+	s.lastActive = time.Now()
+
+	st.notify()
+
+	return st.generation
+}
+
+func (s *session) detach(st *eventStream, generation int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st.attached == generation {
+		st.attached = 0
+	}
+	s.attached--
+	s.lastActive = time.Now()
+
+	if st != s.standalone && st.done && st.delivered >= st.seq {
+		delete(s.streams, st.id)
+	}
+}
+
+// idleSince reports when the session was last used, or the zero time if a
+// client is currently reading from it or waiting for responses.
+func (s *session) idleSince() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attached > 0 {
+		return time.Time{}
+	}
+
+	return s.lastActive
+}
+
+func (s *session) getStream(id int) (*eventStream, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.streams[id]
+	return st, ok
+}
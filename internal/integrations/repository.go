@@ -32,9 +32,27 @@ type HandlerRemover interface {
 type IntegrationsRepository interface {
 	Close() error
 
-	InstallIntegration(ctx context.Context, m *registry.IntegrationManifest) (*InstalledIntegration, error)
+	InstallIntegration(ctx context.Context, m *registry.IntegrationManifest, env map[string]string) (*InstalledIntegration, error)
 	ListIntegrations(ctx context.Context) ([]*InstalledIntegration, error)
 	UninstallIntegration(ctx context.Context, i *InstalledIntegration) error
 
 	OnIntegrationsChanged(cb IntegrationsChangedCallback) HandlerRemover
 }
+
+// MissingEnvVars returns the keys of the environment variables that the
+// manifest requires but that are not set in env.
+func MissingEnvVars(m *registry.IntegrationManifest, env map[string]string) []string {
+	var missing []string
+
+	for _, envVar := range m.EnvVars {
+		if !envVar.Required {
+			continue
+		}
+
+		if value, ok := env[envVar.Key]; !ok || value == "" {
+			missing = append(missing, envVar.Key)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,52 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mcp/internal/audit"
+	"time"
+)
+
+var _ audit.AuditLog = &databaseAuditLog{}
+
+type databaseAuditLog struct {
+	logger *slog.Logger
+	db     *sql.DB
+}
+
+func NewSQLDatabaseAuditLog(logger *slog.Logger, db *sql.DB) audit.AuditLog {
+	return &databaseAuditLog{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Close leaves the database open, as it is shared and closed by whoever
+// opened it.
+func (l *databaseAuditLog) Close() error {
+	return nil
+}
+
+var queryInsertAuditRecord = `
+INSERT INTO audit_log (session_id, type, timestamp, jsonrpc_version, operation, request_id, origin)
+VALUES (?, ?, ?, '2.0', ?, ?, ?)
+`
+
+func (l *databaseAuditLog) Record(ctx context.Context, r *audit.Record) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	operation, err := json.Marshal(r.Operation)
+	if err != nil {
+		return fmt.Errorf("error encoding audited operation: %w", err)
+	}
+
+	if _, err := l.db.ExecContext(ctx, queryInsertAuditRecord, r.SessionId, string(r.Type), time.Now().UTC(), string(operation), r.RequestId, r.Origin); err != nil {
+		return fmt.Errorf("error inserting audit record: %w", err)
+	}
+
+	return nil
+}
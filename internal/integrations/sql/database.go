@@ -0,0 +1,87 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "modernc.org/sqlite"
+)
+
+// BUSY_TIMEOUT_MILLISECONDS is how long a connection waits for a lock on the
+// database held by another, such as that of another mcp process, before
+// failing with SQLITE_BUSY.
+const BUSY_TIMEOUT_MILLISECONDS = 5000
+
+//go:embed migrations/*.sql
+var migrationsDir embed.FS
+
+// OpenDatabase brings the database at dsnURI up to date with the embedded
+// migrations and returns a connection pool to it, to be shared by the
+// repositories using the database and closed by the caller. The pool holds a
+// single connection, which serializes the writes of concurrent sessions
+// rather than have them fail on SQLite's database lock.
+func OpenDatabase(_ context.Context, logger *slog.Logger, dsnURI string) (*sql.DB, error) {
+	var err error
+
+	dsn := withBusyTimeout(dsnURI)
+
+	localDb, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer localDb.Close()
+
+	dir, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error creating migrations source: %w", err)
+	}
+	defer dir.Close()
+
+	instance, err := sqlite.WithInstance(localDb, &sqlite.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating migrations instance: %w", err)
+	}
+
+	migrations, err := migrate.NewWithInstance("iofs", dir, "sqlite:/"+dsnURI, instance)
+	if err != nil {
+		return nil, fmt.Errorf("error creating migrations: %w", err)
+	}
+	defer migrations.Close()
+
+	err = migrations.Up()
+	if err != nil {
+		if err != migrate.ErrNoChange {
+			return nil, fmt.Errorf("error running migrations: %w", err)
+		}
+		err = nil
+	}
+	if err != migrate.ErrNoChange {
+		logger.Debug("migrations completed successfully", "uri", dsnURI)
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+// withBusyTimeout adds the busy timeout pragma to the parameters of dsnURI.
+func withBusyTimeout(dsnURI string) string {
+	separator := "?"
+	if strings.Contains(dsnURI, "?") {
+		separator = "&"
+	}
+
+	return fmt.Sprintf("%s%s_pragma=busy_timeout(%d)", dsnURI, separator, BUSY_TIMEOUT_MILLISECONDS)
+}
@@ -0,0 +1,4 @@
+ALTER TABLE integrations DROP COLUMN env;
+ALTER TABLE integrations DROP COLUMN args;
+ALTER TABLE integrations DROP COLUMN command;
+ALTER TABLE integrations DROP COLUMN version;
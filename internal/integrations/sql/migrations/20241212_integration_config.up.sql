@@ -0,0 +1,7 @@
+-- Persist everything needed to start an installed integration.
+ALTER TABLE integrations ADD COLUMN version TEXT;
+ALTER TABLE integrations ADD COLUMN command TEXT;
+-- JSON array of arguments passed to the command.
+ALTER TABLE integrations ADD COLUMN args TEXT;
+-- JSON object of environment variables passed to the integration.
+ALTER TABLE integrations ADD COLUMN env TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tool_suggestions_normalized_name;
+DROP TABLE IF EXISTS tool_suggestions;
@@ -0,0 +1,16 @@
+-- The tool suggestions table tracks the tools that models wished existed.
+-- Suggestions with similar names share the same normalized name and are
+-- merged into a single row.
+CREATE TABLE IF NOT EXISTS tool_suggestions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  normalized_name TEXT NOT NULL,
+  description TEXT,
+  input_schema JSONB,
+  session_id TEXT NOT NULL,
+  suggestion_count INTEGER NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL,
+  last_suggested_at TIMESTAMP NOT NULL
+);
+-- Adding indices
+CREATE UNIQUE INDEX idx_tool_suggestions_normalized_name ON tool_suggestions (normalized_name);
@@ -0,0 +1,2 @@
+-- Attribute audited operations to the party that initiated them.
+ALTER TABLE audit_log ADD COLUMN origin TEXT;
@@ -0,0 +1,3 @@
+ALTER TABLE integrations DROP COLUMN headers;
+ALTER TABLE integrations DROP COLUMN transport;
+ALTER TABLE integrations DROP COLUMN url;
@@ -0,0 +1,5 @@
+-- Persist how to reach integrations of the remote runtime.
+ALTER TABLE integrations ADD COLUMN url TEXT;
+ALTER TABLE integrations ADD COLUMN transport TEXT;
+-- JSON object of headers sent with every request to the integration.
+ALTER TABLE integrations ADD COLUMN headers TEXT;
@@ -3,94 +3,93 @@ package sql
 import (
 	"context"
 	"database/sql"
-	"embed"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"mcp/internal/integrations"
 	"mcp/internal/registry"
+	"strconv"
 	"sync"
 	"time"
-
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/sqlite"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
-	_ "modernc.org/sqlite"
 )
 
-//go:embed migrations/*.sql
-var migrationsDir embed.FS
-
 var _ integrations.IntegrationsRepository = &databaseIntegrationsRepository{}
 
 type databaseIntegrationsRepository struct {
-	callbacks   map[struct{}]integrations.IntegrationsChangedCallback
-	callbacksMu sync.RWMutex
+	callbacks      map[uint64]integrations.IntegrationsChangedCallback
+	callbacksMu    sync.RWMutex
+	nextCallbackId uint64
 
 	logger *slog.Logger
 	db     *sql.DB
 }
 
-func NewSQLDatabaseIntegrationsRepository(ctx context.Context, logger *slog.Logger, dsnURI string) (integrations.IntegrationsRepository, error) {
-	var err error
-
-	localDb, err := sql.Open("sqlite", dsnURI)
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to database: %w", err)
+func NewSQLDatabaseIntegrationsRepository(logger *slog.Logger, db *sql.DB) integrations.IntegrationsRepository {
+	return &databaseIntegrationsRepository{
+		callbacks: make(map[uint64]integrations.IntegrationsChangedCallback),
+		db:        db,
+		logger:    logger,
 	}
-	defer localDb.Close()
+}
+
+// Close leaves the database open, as it is shared and closed by whoever
+// opened it.
+func (r *databaseIntegrationsRepository) Close() error {
+	return nil
+}
+
+var queryInstallIntegration = `
+INSERT INTO integrations (name, version, description, vendor, source_url, homepage, license, runtime, command, args, env, url, transport, headers)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+func (r *databaseIntegrationsRepository) InstallIntegration(ctx context.Context, m *registry.IntegrationManifest, env map[string]string) (*integrations.InstalledIntegration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
 
-	dir, err := iofs.New(migrationsDir, "migrations")
+	args, err := json.Marshal(m.Args)
 	if err != nil {
-		return nil, fmt.Errorf("error creating migrations source: %w", err)
+		return nil, fmt.Errorf("error encoding integration args: %w", err)
 	}
-	defer dir.Close()
 
-	instance, err := sqlite.WithInstance(localDb, &sqlite.Config{})
+	encodedEnv, err := json.Marshal(env)
 	if err != nil {
-		return nil, fmt.Errorf("error creating migrations instance: %w", err)
+		return nil, fmt.Errorf("error encoding integration env: %w", err)
 	}
 
-	migrations, err := migrate.NewWithInstance("iofs", dir, "sqlite:/"+dsnURI, instance)
+	headers, err := json.Marshal(m.Headers)
 	if err != nil {
-		return nil, fmt.Errorf("error creating migrations: %w", err)
+		return nil, fmt.Errorf("error encoding integration headers: %w", err)
 	}
-	defer migrations.Close()
 
-	err = migrations.Up()
+	res, err := r.db.ExecContext(ctx, queryInstallIntegration, m.Name, m.Version, m.Description, m.Vendor, m.SourceURL, m.Homepage, m.License, m.Runtime, m.Command, string(args), string(encodedEnv), m.URL, m.Transport, string(headers))
 	if err != nil {
-		if err != migrate.ErrNoChange {
-			return nil, fmt.Errorf("error running migrations: %w", err)
-		}
-		err = nil
-	}
-	if err != migrate.ErrNoChange {
-		logger.Debug("migrations completed successfully", "uri", dsnURI)
+		return nil, fmt.Errorf("error inserting integration: %w", err)
 	}
 
-	db, err := sql.Open("sqlite", dsnURI)
+	id, err := res.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to database: %w", err)
+		return nil, fmt.Errorf("error reading installed integration id: %w", err)
 	}
 
-	return &databaseIntegrationsRepository{
-		callbacks: make(map[struct{}]integrations.IntegrationsChangedCallback),
-		db:        db,
-		logger:    logger,
-	}, nil
-}
+	installed := &integrations.InstalledIntegration{
+		Id:       strconv.FormatInt(id, 10),
+		Manifest: m,
+		Env:      env,
+	}
 
-func (r *databaseIntegrationsRepository) Close() error {
-	r.logger.Debug("closing database")
-	return r.db.Close()
-}
+	r.emit(&integrations.IntegrationsChangedEvent{
+		Type:        integrations.IntegrationsChangedEventTypeAdded,
+		Integration: *installed,
+	})
 
-func (r *databaseIntegrationsRepository) InstallIntegration(ctx context.Context, m *registry.IntegrationManifest) (*integrations.InstalledIntegration, error) {
-	return nil, nil
+	return installed, nil
 }
 
 var queryInstalledIntegrations = `
-SELECT id, name, description, vendor, source_url, homepage, license, runtime
+SELECT id, name, version, description, vendor, source_url, homepage, license, runtime, command, args, env, url, transport, headers
 FROM integrations
+ORDER BY id
 `
 
 func (r *databaseIntegrationsRepository) ListIntegrations(ctx context.Context) ([]*integrations.InstalledIntegration, error) {
@@ -101,8 +100,9 @@ func (r *databaseIntegrationsRepository) ListIntegrations(ctx context.Context) (
 	if err != nil {
 		return nil, fmt.Errorf("error preparing list integrations query: %w", err)
 	}
+	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, nil)
+	rows, err := stmt.QueryContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error querying installed integrations: %w", err)
 	}
@@ -111,15 +111,60 @@ func (r *databaseIntegrationsRepository) ListIntegrations(ctx context.Context) (
 	var installed []*integrations.InstalledIntegration
 
 	for rows.Next() {
-		var i integrations.InstalledIntegration
-
-		if err := rows.Scan(&i.Id, &i.Manifest.Name, &i.Manifest.Description, &i.Manifest.Vendor, &i.Manifest.SourceURL, &i.Manifest.Homepage, &i.Manifest.License, &i.Manifest.Runtime); err != nil {
+		var (
+			id                                                                       string
+			name                                                                     string
+			version, description, vendor, sourceURL, homepage, license, runtime, cmd sql.NullString
+			args, env                                                                sql.NullString
+			url, transport, headers                                                  sql.NullString
+		)
+
+		if err := rows.Scan(&id, &name, &version, &description, &vendor, &sourceURL, &homepage, &license, &runtime, &cmd, &args, &env, &url, &transport, &headers); err != nil {
 			return nil, fmt.Errorf("error scanning installed integration: %w", err)
 		}
 
+		i := integrations.InstalledIntegration{
+			Id: id,
+			Manifest: &registry.IntegrationManifest{
+				Name:        name,
+				Version:     version.String,
+				Description: description.String,
+				Vendor:      vendor.String,
+				SourceURL:   sourceURL.String,
+				Homepage:    homepage.String,
+				License:     license.String,
+				Runtime:     runtime.String,
+				Command:     cmd.String,
+				URL:         url.String,
+				Transport:   transport.String,
+			},
+		}
+
+		if args.Valid {
+			if err := json.Unmarshal([]byte(args.String), &i.Manifest.Args); err != nil {
+				return nil, fmt.Errorf("error decoding args of integration %s: %w", id, err)
+			}
+		}
+
+		if env.Valid {
+			if err := json.Unmarshal([]byte(env.String), &i.Env); err != nil {
+				return nil, fmt.Errorf("error decoding env of integration %s: %w", id, err)
+			}
+		}
+
+		if headers.Valid {
+			if err := json.Unmarshal([]byte(headers.String), &i.Manifest.Headers); err != nil {
+				return nil, fmt.Errorf("error decoding headers of integration %s: %w", id, err)
+			}
+		}
+
 		installed = append(installed, &i)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating installed integrations: %w", err)
+	}
+
 	return installed, nil
 }
 
@@ -131,7 +176,8 @@ func (r *databaseIntegrationsRepository) OnIntegrationsChanged(cb integrations.I
 	r.callbacksMu.Lock()
 	defer r.callbacksMu.Unlock()
 
-	key := struct{}{}
+	key := r.nextCallbackId
+	r.nextCallbackId++
 	r.callbacks[key] = cb
 
 	return &handlerRemover[integrations.IntegrationsChangedCallback]{
@@ -141,10 +187,24 @@ func (r *databaseIntegrationsRepository) OnIntegrationsChanged(cb integrations.I
 	}
 }
 
+// emit synchronously invokes the registered callbacks with the event.
+func (r *databaseIntegrationsRepository) emit(e *integrations.IntegrationsChangedEvent) {
+	r.callbacksMu.RLock()
+	callbacks := make([]integrations.IntegrationsChangedCallback, 0, len(r.callbacks))
+	for _, cb := range r.callbacks {
+		callbacks = append(callbacks, cb)
+	}
+	r.callbacksMu.RUnlock()
+
+	for _, cb := range callbacks {
+		cb(e)
+	}
+}
+
 type handlerRemover[T any] struct {
 	mu        *sync.RWMutex
-	key       struct{}
-	callbacks map[struct{}]T
+	key       uint64
+	callbacks map[uint64]T
 }
 
 func (hr *handlerRemover[T]) Close() {
@@ -0,0 +1,115 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mcp/internal/suggestions"
+	"time"
+)
+
+var _ suggestions.SuggestionsRepository = &databaseSuggestionsRepository{}
+
+type databaseSuggestionsRepository struct {
+	logger *slog.Logger
+	db     *sql.DB
+}
+
+func NewSQLDatabaseSuggestionsRepository(logger *slog.Logger, db *sql.DB) suggestions.SuggestionsRepository {
+	return &databaseSuggestionsRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Close leaves the database open, as it is shared and closed by whoever
+// opened it.
+func (r *databaseSuggestionsRepository) Close() error {
+	return nil
+}
+
+var queryUpsertSuggestion = `
+INSERT INTO tool_suggestions (name, normalized_name, description, input_schema, session_id, created_at, last_suggested_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (normalized_name) DO UPDATE SET
+  suggestion_count = suggestion_count + 1,
+  last_suggested_at = excluded.last_suggested_at
+`
+
+func (r *databaseSuggestionsRepository) SuggestTools(ctx context.Context, sessionId string, tools []suggestions.SuggestedTool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+
+	for _, tool := range tools {
+		inputSchema, err := json.Marshal(tool.InputSchema)
+		if err != nil {
+			return fmt.Errorf("error encoding input schema of suggested tool %q: %w", tool.Name, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, queryUpsertSuggestion, tool.Name, suggestions.NormalizeName(tool.Name), tool.Description, string(inputSchema), sessionId, now, now); err != nil {
+			return fmt.Errorf("error recording suggested tool %q: %w", tool.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing suggested tools: %w", err)
+	}
+
+	return nil
+}
+
+var queryListSuggestions = `
+SELECT id, name, description, input_schema, session_id, suggestion_count, created_at, last_suggested_at
+FROM tool_suggestions
+ORDER BY suggestion_count DESC, last_suggested_at DESC
+`
+
+func (r *databaseSuggestionsRepository) ListSuggestions(ctx context.Context) ([]*suggestions.Suggestion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, queryListSuggestions)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tool suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	var found []*suggestions.Suggestion
+
+	for rows.Next() {
+		var (
+			s                        suggestions.Suggestion
+			description, inputSchema sql.NullString
+		)
+
+		if err := rows.Scan(&s.Id, &s.Name, &description, &inputSchema, &s.SessionId, &s.Count, &s.CreatedAt, &s.LastSuggestedAt); err != nil {
+			return nil, fmt.Errorf("error scanning tool suggestion: %w", err)
+		}
+
+		s.Description = description.String
+
+		if inputSchema.Valid {
+			if err := json.Unmarshal([]byte(inputSchema.String), &s.InputSchema); err != nil {
+				return nil, fmt.Errorf("error decoding input schema of tool suggestion %s: %w", s.Id, err)
+			}
+		}
+
+		found = append(found, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tool suggestions: %w", err)
+	}
+
+	return found, nil
+}
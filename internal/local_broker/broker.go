@@ -3,37 +3,90 @@ package localbroker
 import (
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
+	"mcp/internal/audit"
 	"mcp/internal/integrations"
-	"mcp/internal/jsonrpc"
 	"mcp/internal/mcp"
+	"mcp/internal/registry"
 	serverrunner "mcp/internal/server_runner"
+	"mcp/internal/suggestions"
 	"mcp/internal/util"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/sync/errgroup"
 )
 
-const (
-	DEFAULT_START_TIMEOUT_SECONDS = 30
-)
+const DEFAULT_START_TIMEOUT_SECONDS = 30
+
+// INTEGRATIONS_POLL_INTERVAL_SECONDS is how often the repository is checked
+// for integrations installed or uninstalled by other processes, such as
+// `mcp package install`, whose change events the broker doesn't receive.
+const INTEGRATIONS_POLL_INTERVAL_SECONDS = 5
 
 var ErrConnectionClosed = fmt.Errorf("connection closed")
 
+// LocalBroker aggregates the installed servers for any number of clients.
+// Run manages the child servers while each client is served by ServeSession.
 type LocalBroker interface {
 	Close() error
 	Run(ctx context.Context) error
+	ServeSession(ctx context.Context, stream jsonrpc2.ObjectStream) error
 }
 
 var _ LocalBroker = &localBroker{}
 
+// LocalBrokerOptions configures optional behaviours of the local broker.
+type LocalBrokerOptions struct {
+	// MountRoots bind-mounts the client's file:// roots into the child servers'
+	// containers and rewrites the roots served to children accordingly.
+	MountRoots bool
+
+	// RestartPolicy determines what happens to children that stop responding
+	// to pings.
+	RestartPolicy RestartPolicy
+
+	// ValidateOutput checks the structured content returned by child tools
+	// against their output schemas.
+	ValidateOutput bool
+}
+
 type localBroker struct {
+	options     LocalBrokerOptions
 	integRepo   integrations.IntegrationsRepository
 	integRunner serverrunner.ServerStarter
+	registry    registry.RegistryClient
+	suggestions suggestions.SuggestionsRepository
+	auditLog    audit.AuditLog
 	logger      *slog.Logger
-	conn        *jsonrpc2.Conn
+
+	children   map[string]*childServer
+	childrenMu sync.RWMutex
+	// startMu serializes starting integrations so that each gets one child.
+	startMu sync.Mutex
+	// started records the integrations started since Run began, guarded by
+	// startMu, so that polling the repository only starts those installed
+	// since and doesn't revive children that were stopped on purpose.
+	started map[string]bool
+
+	sessions   []*session
+	sessionsMu sync.RWMutex
+
+	// crashes counts, by integration id, the children that stopped on their
+	// own since the integration last answered a ping.
+	crashes   map[string]int
+	crashesMu sync.Mutex
+
+	// inflight tracks the requests issued by children, those issued by
+	// clients being tracked by their session.
+	inflight *inflightRequests
+	progress *progressTokens
+
+	// runCtx is the context passed to Run, used to restart children outside
+	// of the request that triggered the restart.
+	runCtx context.Context
 
 	integrationStartTimeout time.Duration
 }
@@ -43,41 +96,59 @@ func NewLocalBroker(
 	logger *slog.Logger,
 	integRepo integrations.IntegrationsRepository,
 	runner serverrunner.ServerStarter,
-	r io.ReadCloser,
-	w io.WriteCloser,
+	registryClient registry.RegistryClient,
+	suggestionsRepo suggestions.SuggestionsRepository,
+	auditLog audit.AuditLog,
+	options LocalBrokerOptions,
 ) LocalBroker {
 	lb := &localBroker{
+		options:     options,
 		integRepo:   integRepo,
 		integRunner: runner,
+		registry:    registryClient,
+		suggestions: suggestionsRepo,
+		auditLog:    auditLog,
 		logger:      logger,
+		children:    make(map[string]*childServer),
+		started:     make(map[string]bool),
+		crashes:     make(map[string]int),
+
+		inflight: newInflightRequests(),
+		progress: newProgressTokens(),
 
 		integrationStartTimeout: time.Duration(DEFAULT_START_TIMEOUT_SECONDS) * time.Second,
 	}
 
-	handler := jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(lb.handleRequest).SuppressErrClosed())
-	stream := jsonrpc2.NewPlainObjectStream(util.NewReaderWriterCloser(r, w))
-
-	lb.conn = jsonrpc2.NewConn(ctx, stream, handler, jsonrpc2.LogMessages(jsonrpc.NewJSONRPCLogger(lb.logger)))
-
 	return lb
 }
 
+// Close disconnects every client.
 func (lb *localBroker) Close() error {
-	lb.conn.Close()
+	for _, sess := range lb.listSessions() {
+		sess.conn.Close()
+	}
+
 	return nil
 }
 
+// Run starts the installed integrations and keeps them in sync with the
+// repository until ctx is cancelled.
 func (lb *localBroker) Run(ctx context.Context) error {
 	defer lb.Close()
 
-	lb.integRepo.OnIntegrationsChanged(func(e *integrations.IntegrationsChangedEvent) {
+	lb.runCtx = ctx
+
+	handlerRemover := lb.integRepo.OnIntegrationsChanged(func(e *integrations.IntegrationsChangedEvent) {
 		switch e.Type {
 		case integrations.IntegrationsChangedEventTypeAdded:
-			go lb.startIntegration(ctx, e.Integration)
+			if _, err := lb.startIntegration(ctx, e.Integration); err != nil {
+				lb.logger.Error("error starting integration", "id", e.Integration.Id, "err", err)
+			}
 		case integrations.IntegrationsChangedEventTypeRemoved:
 			go lb.stopIntegration(ctx, e.Integration)
 		}
 	})
+	defer handlerRemover.Close()
 
 	installed, err := lb.integRepo.ListIntegrations(ctx)
 	if err != nil {
@@ -88,78 +159,319 @@ func (lb *localBroker) Run(ctx context.Context) error {
 
 	for _, integration := range installed {
 		lb.logger.Info("bootstrapping integration", "id", integration.Id)
-		go lb.startIntegration(ctx, *integration)
+		if _, err := lb.startIntegration(ctx, *integration); err != nil {
+			lb.logger.Error("error starting integration", "id", integration.Id, "err", err)
+		}
 	}
 
-	select {
-	case <-ctx.Done():
-	case <-lb.conn.DisconnectNotify():
-		return ErrConnectionClosed
+	ticker := time.NewTicker(INTEGRATIONS_POLL_INTERVAL_SECONDS * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			lb.syncIntegrations(ctx)
+		}
 	}
+}
 
-	return nil
+// syncIntegrations starts the integrations installed and stops those
+// uninstalled since they were last seen, which the repository's events only
+// report when the change is made by this process.
+func (lb *localBroker) syncIntegrations(ctx context.Context) {
+	installed, err := lb.integRepo.ListIntegrations(ctx)
+	if err != nil {
+		lb.logger.Error("error listing integrations", "err", err)
+		return
+	}
+
+	lb.startMu.Lock()
+	var added []*integrations.InstalledIntegration
+	listed := make(map[string]bool, len(installed))
+	for _, integration := range installed {
+		listed[integration.Id] = true
+		if !lb.started[integration.Id] {
+			added = append(added, integration)
+		}
+	}
+
+	var removed []string
+	for id := range lb.started {
+		if !listed[id] {
+			removed = append(removed, id)
+			delete(lb.started, id)
+		}
+	}
+	lb.startMu.Unlock()
+
+	for _, integration := range added {
+		lb.logger.Info("found newly installed integration", "id", integration.Id)
+		if _, err := lb.startIntegration(ctx, *integration); err != nil {
+			lb.logger.Error("error starting integration", "id", integration.Id, "err", err)
+		}
+	}
+
+	for _, id := range removed {
+		lb.stopIntegration(ctx, integrations.InstalledIntegration{Id: id})
+	}
 }
 
-func (lb *localBroker) startIntegration(ctx context.Context, integration integrations.InstalledIntegration) {
-	ctx, cancel := context.WithTimeout(ctx, lb.integrationStartTimeout)
-	defer cancel()
+// startIntegration registers a child server for the integration and runs it
+// in the background until ctx is cancelled or the server exits. An
+// integration that already has a child, such as one installed while Run was
+// bootstrapping and so both listed and reported as added, keeps it.
+func (lb *localBroker) startIntegration(ctx context.Context, integration integrations.InstalledIntegration) (*childServer, error) {
+	lb.startMu.Lock()
+	defer lb.startMu.Unlock()
+
+	if child, ok := lb.getChild(integration.Id); ok {
+		return child, nil
+	}
+
+	// An integration that fails to start isn't retried by syncIntegrations.
+	lb.started[integration.Id] = true
+
+	ctx, cancel := context.WithCancel(ctx)
 
 	lb.logger.Info("starting integration", "id", integration.Id)
 
+	var mounts []serverrunner.Mount
+	if !isRemote(integration) {
+		mounts = lb.childMounts()
+	}
+
 	srv, err := lb.integRunner.Create(ctx, serverrunner.ServerDescription{
-		Runtime: integration.Manifest.Runtime,
-		Command: integration.Manifest.Command,
-		Args:    integration.Manifest.Args,
-		Env:     integration.Env,
+		Runtime:   integration.Manifest.Runtime,
+		Command:   integration.Manifest.Command,
+		Args:      integration.Manifest.Args,
+		Env:       integration.Env,
+		URL:       integration.Manifest.URL,
+		Transport: integration.Manifest.Transport,
+		Headers:   integration.Manifest.Headers,
+		Mounts:    mounts,
+		Stderr:    lb.childStderr(integration.Id),
+		Capabilities: mcp.ClientCapabilities{
+			Roots: &mcp.ListChangesCapability{
+				ListChanged: util.Ptr(true),
+			},
+			// Sampling is declared whatever the clients, which may come and
+			// go while the child runs, each request being refused if the
+			// client it is routed to doesn't support sampling.
+			Sampling: &mcp.SamplingCapability{},
+		},
+		Handler: func(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+			return lb.handleChildRequest(ctx, integration.Id, req)
+		},
 	})
 	if err != nil {
-		lb.logger.Error("error creating integration", "id", integration.Id, "err", err)
-		return
+		cancel()
+		return nil, fmt.Errorf("error creating integration: %w", err)
 	}
 
-	if err := srv.Run(ctx); err != nil {
-		lb.logger.Error("error running integration", "id", integration.Id, "err", err)
+	child := lb.addChild(integration, srv, cancel)
+
+	go lb.runChild(ctx, child)
+
+	return child, nil
+}
+
+// runChild runs the child server until ctx is cancelled or the server exits,
+// removing the child from the broker afterwards. A child that exits or
+// disconnects without having been stopped is restarted according to the
+// restart policy.
+func (lb *localBroker) runChild(ctx context.Context, child *childServer) {
+	crashed := false
+	defer func() {
+		if crashed {
+			go lb.restartCrashedChild(child)
+		}
+	}()
+	defer child.cancel()
+	defer close(child.done)
+	defer lb.removeIntegrationById(ctx, child.integrationId)
+
+	childCtx := ctx
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		return child.instance.Run(ctx)
+	})
+
+	g.Go(func() error {
+		return lb.connectChild(ctx, child)
+	})
+
+	if err := g.Wait(); err != nil {
+		lb.logger.Error("error running integration", "id", child.integrationId, "err", err)
 	}
 
-	lb.removeIntegrationById(ctx, integration.Id)
+	crashed = childCtx.Err() == nil
+}
+
+// connectChild waits for the child to complete its initialization handshake,
+// records the tools it advertises and then monitors its health.
+func (lb *localBroker) connectChild(ctx context.Context, child *childServer) error {
+	startCtx, cancel := context.WithTimeout(ctx, lb.integrationStartTimeout)
+	defer cancel()
 
+	conn, err := child.instance.Conn(startCtx)
+	if err != nil {
+		return fmt.Errorf("error waiting for integration to start: %w", err)
+	}
+
+	child.setConn(conn)
+
+	if level := lb.childLoggingLevel(); level != "" {
+		lb.forwardLoggingLevel(ctx, child, conn, level)
+	}
+
+	tools, err := child.listTools(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing integration tools: %w", err)
+	}
+
+	adaptChildTools(tools, conn.InitializeResult().ProtocolVersion)
+	lb.setChildTools(child, tools)
+	close(child.ready)
+
+	lb.logger.Info("integration ready", "id", child.integrationId, "prefix", child.prefix, "tools", len(tools))
+
+	lb.notifyListChanged(ctx, listChangedNotifications(conn.InitializeResult().Capabilities)...)
+
+	return lb.monitorChild(ctx, child, conn)
 }
 
 func (lb *localBroker) stopIntegration(_ context.Context, integration integrations.InstalledIntegration) {
 	lb.logger.Info("stopping integration", "id", integration.Id)
+
+	if child, ok := lb.getChild(integration.Id); ok {
+		child.cancel()
+	}
 }
 
-func (lb *localBroker) removeIntegrationById(_ context.Context, integrationId string) {
+func (lb *localBroker) removeIntegrationById(ctx context.Context, integrationId string) {
 	lb.logger.Info("removing integration", "id", integrationId)
+
+	child, ok := lb.getChild(integrationId)
+	if !ok {
+		return
+	}
+
+	lb.deleteChild(integrationId)
+	for _, sess := range lb.listSessions() {
+		sess.subscriptions.dropChild(integrationId)
+	}
+
+	if conn, ok := child.getConn(); ok {
+		lb.notifyListChanged(context.WithoutCancel(ctx), listChangedNotifications(conn.InitializeResult().Capabilities)...)
+	}
 }
 
-func (lb *localBroker) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result interface{}, err error) {
-	lb.logger.Debug("handling request", "method", req.Method)
+func (lb *localBroker) handleRequest(ctx context.Context, sess *session, req *jsonrpc2.Request) (result interface{}, err error) {
+	sess.logger.Debug("handling request", "method", req.Method)
+
+	if !req.Notif {
+		var done func()
+		ctx, done = sess.inflight.track(ctx, ORIGIN_CLIENT, req.ID)
+		defer done()
+	}
+
 	switch req.Method {
+	case "ping":
+		return &mcp.EmptyResult{}, nil
 	case "initialize":
 		req, err := mcp.MustParams[mcp.InitializeRequest](req)
 		if err != nil {
 			return nil, err
 		}
-		return lb.handleInitializeRequest(ctx, conn, req)
-	case "initialized":
-		req, err := mcp.MustParams[mcp.InitializedNotification](req)
+		return lb.handleInitializeRequest(ctx, sess, req)
+	case "initialized", "notifications/initialized":
+		req, err := mcp.OptionalParams[mcp.InitializedNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lb.handleInitializedNotification(ctx, sess, req)
+	case NOTIFICATION_CANCELLED:
+		req, err := mcp.MustParams[mcp.CancelledNotification](req)
 		if err != nil {
 			return nil, err
 		}
-		return nil, lb.handleInitializedNotification(ctx, conn, req)
+		lb.handleCancelledNotification(sess.inflight, ORIGIN_CLIENT, req)
+		return nil, nil
+	case NOTIFICATION_ROOTS_LIST_CHANGED:
+		req, err := mcp.OptionalParams[mcp.ListChangedNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lb.handleRootsListChangedNotification(ctx, sess, req)
+	case "completion/complete":
+		req, err := mcp.MustParams[mcp.CompleteRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleCompleteRequest(ctx, sess, req)
+	case "logging/setLevel":
+		req, err := mcp.MustParams[mcp.LoggingSetLevelRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleLoggingSetLevelRequest(ctx, sess, req)
 	case "tools/call":
 		req, err := mcp.MustParams[mcp.ToolsCallRequest](req)
 		if err != nil {
 			return nil, err
 		}
-		return lb.handleToolsCallRequest(ctx, conn, req)
+		return lb.handleToolsCallRequest(ctx, sess, req)
 	case "tools/list":
-		req, err := mcp.MustParams[mcp.ToolsListRequest](req)
+		req, err := mcp.OptionalParams[mcp.ToolsListRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleToolsListRequest(ctx, sess, req)
+	case "resources/list":
+		req, err := mcp.OptionalParams[mcp.ResourcesListRequest](req)
 		if err != nil {
 			return nil, err
 		}
-		return lb.handleToolsListRequest(ctx, conn, req)
+		return lb.handleResourcesListRequest(ctx, sess, req)
+	case "resources/templates/list":
+		req, err := mcp.OptionalParams[mcp.ResourceTemplatesListRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleResourceTemplatesListRequest(ctx, sess, req)
+	case "resources/read":
+		req, err := mcp.MustParams[mcp.ResourcesReadRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleResourcesReadRequest(ctx, sess, req)
+	case "resources/subscribe":
+		req, err := mcp.MustParams[mcp.ResourcesSubscribeRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleResourcesSubscribeRequest(ctx, sess, req)
+	case "resources/unsubscribe":
+		req, err := mcp.MustParams[mcp.ResourcesUnsubscribeRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleResourcesUnsubscribeRequest(ctx, sess, req)
+	case "prompts/list":
+		req, err := mcp.OptionalParams[mcp.PromptsListRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handlePromptsListRequest(ctx, sess, req)
+	case "prompts/get":
+		req, err := mcp.MustParams[mcp.PromptsGetRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handlePromptsGetRequest(ctx, sess, req)
 	default:
 		return nil, &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeMethodNotFound,
@@ -168,8 +480,16 @@ func (lb *localBroker) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, r
 	}
 }
 
-func (lb *localBroker) handleInitializeRequest(_ context.Context, _ *jsonrpc2.Conn, _ *mcp.InitializeRequest) (*mcp.InitializeResult, error) {
-	// TODO: Negotiate protocol versions
+func (lb *localBroker) handleInitializeRequest(ctx context.Context, sess *session, req *mcp.InitializeRequest) (*mcp.InitializeResult, error) {
+	protocolVersion := mcp.NegotiateProtocolVersion(req.ProtocolVersion)
+
+	sess.logger.Info("client initializing", "name", req.ClientInfo.Name, "version", req.ClientInfo.Version, "requestedProtocolVersion", req.ProtocolVersion, "protocolVersion", protocolVersion)
+
+	sess.setClient(clientState{
+		protocolVersion: protocolVersion,
+		capabilities:    req.Capabilities,
+		info:            req.ClientInfo,
+	})
 
 	instructions := strings.TrimSpace(`
 # Introduction
@@ -211,10 +531,31 @@ installed child servers can fulfill the request.
 	generically useful for the request and other anticipated requests.
 			`)
 
+	// Capabilities are fixed once the client is initialized, MCP having no
+	// notification for changing them, so completions are only offered when a
+	// child that is already running supports them. Children starting later
+	// don't make them available to the client.
+	var completions *mcp.CompletionsCapability
+	if lb.anyChildSupportsCompletions() {
+		completions = &mcp.CompletionsCapability{}
+		sess.completions.Store(true)
+	}
+
 	return &mcp.InitializeResult{
-		ProtocolVersion: mcp.MCP_PROTOCOL_VERSION,
+		ProtocolVersion: protocolVersion,
 		Capabilities: mcp.ServerCapabilities{
-			Logging: &mcp.LoggingCapability{},
+			Completions: completions,
+			Logging:     &mcp.LoggingCapability{},
+			Prompts: &mcp.ListChangesCapability{
+				ListChanged: util.Ptr(true),
+			},
+			Resources: &mcp.SubscribeAndListChangesCapability{
+				Subscribe:   util.Ptr(true),
+				ListChanged: util.Ptr(true),
+			},
+			Tools: &mcp.ListChangesCapability{
+				ListChanged: util.Ptr(true),
+			},
 		},
 		ServerInfo: mcp.ImplementationInfo{
 			Name:    "mcp",
@@ -224,36 +565,103 @@ installed child servers can fulfill the request.
 	}, nil
 }
 
-func (lb *localBroker) handleInitializedNotification(_ context.Context, _ *jsonrpc2.Conn, _ *mcp.InitializedNotification) error {
+func (lb *localBroker) handleInitializedNotification(ctx context.Context, sess *session, _ *mcp.InitializedNotification) error {
+	sess.initialized.Store(true)
+
+	if err := lb.refreshRoots(ctx, sess); err != nil {
+		sess.logger.Error("error fetching client roots", "err", err)
+	}
+
 	return nil
 }
 
-func (lb *localBroker) handleToolsCallRequest(_ context.Context, _ *jsonrpc2.Conn, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+func (lb *localBroker) handleToolsCallRequest(ctx context.Context, sess *session, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+	var result *mcp.ToolsCallResult
+	var err error
+
 	switch req.ToolName {
 	case "__mcp__install_server":
-		return nil, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInternalError,
-			Message: "tool not implemented",
-		}
+		result, err = lb.handleInstallServerTool(ctx, req)
 	case "__mcp__search_registry":
-		return nil, &jsonrpc2.Error{
-			Code:    jsonrpc2.CodeInternalError,
-			Message: "tool not implemented",
-		}
+		result, err = lb.handleSearchRegistryTool(ctx, req)
 	case "__mcp__suggest_tool":
+		result, err = lb.handleSuggestToolTool(ctx, sess, req)
+	default:
+		result, err = lb.forwardToolsCallRequest(ctx, sess, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	adaptToolsCallResult(result, sess.getClient().protocolVersion)
+
+	return result, nil
+}
+
+// forwardToolsCallRequest relays a call to a namespaced tool to the child
+// server that owns it.
+func (lb *localBroker) forwardToolsCallRequest(ctx context.Context, sess *session, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+	errToolNotFound := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeMethodNotFound,
+		Message: fmt.Sprintf("tool %q not found", req.ToolName),
+	}
+
+	prefix, toolName, ok := splitNamespacedName(req.ToolName)
+	if !ok {
+		return nil, errToolNotFound
+	}
+
+	child, ok := lb.getChildByPrefix(prefix)
+	if !ok {
+		return nil, errToolNotFound
+	}
+
+	conn, err := child.readyConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if !child.hasTool(toolName) {
+		return nil, errToolNotFound
+	}
+
+	if !child.isHealthy() {
 		return nil, &jsonrpc2.Error{
 			Code:    jsonrpc2.CodeInternalError,
-			Message: "tool not implemented",
+			Message: fmt.Sprintf("server %q is not responding", prefix),
 		}
 	}
 
-	return nil, &jsonrpc2.Error{
-		Code:    jsonrpc2.CodeMethodNotFound,
-		Message: fmt.Sprintf("tool %q not found", req.ToolName),
+	if err := validateToolArguments(child, req, toolName); err != nil {
+		return nil, err
+	}
+
+	meta, release := lb.progress.forward(sess, req.Meta)
+	defer release()
+
+	var result mcp.ToolsCallResult
+	if err := lb.callChild(ctx, sess, child, conn, "tools/call", &mcp.ToolsCallRequest{
+		Meta:      meta,
+		ToolName:  toolName,
+		Arguments: req.Arguments,
+	}, &result); err != nil {
+		return nil, childCallError(prefix, err)
+	}
+
+	if lb.options.ValidateOutput {
+		if err := validateToolResult(child, toolName, &result); err != nil {
+			return nil, err
+		}
 	}
+
+	for _, content := range result.Content {
+		namespaceContentURI(child.prefix, content)
+	}
+
+	return &result, nil
 }
 
-func (lb *localBroker) handleToolsListRequest(_ context.Context, _ *jsonrpc2.Conn, _ *mcp.ToolsListRequest) (*mcp.ToolsListResult, error) {
+func (lb *localBroker) handleToolsListRequest(_ context.Context, _ *session, req *mcp.ToolsListRequest) (*mcp.ToolsListResult, error) {
 	builtInTools := []mcp.ToolDefinition{
 		{
 			Name: "__mcp__install_server",
@@ -262,18 +670,20 @@ Install a child MCP Server based on a server discovered via ` + "`" + `"__mcp__s
 
 ONLY use this tool if you discover a server that can fulfill the request. If you are not sure
 whether the server can fulfill the request, indicate that you CAN'T fulfill the request.
+
+The environment variables the server requires are taken from the environment ` + "`" + `mcp` + "`" + ` runs in.
 			`),
-			InputSchema: mcp.JSONSchemaObject{
+			InputSchema: mcp.JSONSchema{
 				Type: "object",
-				Properties: map[string]any{
-					"name": map[string]any{
-						"type": "string",
+				Properties: map[string]*mcp.JSONSchema{
+					"name": {
+						Type: "string",
 					},
-					"version": map[string]any{
-						"type": "string",
+					"version": {
+						Type: "string",
 					},
 				},
-				RequiredProperties: []string{"name", "version"},
+				Required: []string{"name", "version"},
 			},
 		},
 		{
@@ -286,45 +696,62 @@ search terms that would be useful for finding a server that can fulfill the requ
 
 If you 
 			`),
-			InputSchema: mcp.JSONSchemaObject{
+			InputSchema: mcp.JSONSchema{
 				Type: "object",
-				Properties: map[string]any{
-					"query": map[string]any{
-						"type": "string",
+				Properties: map[string]*mcp.JSONSchema{
+					"query": {
+						Type: "string",
 					},
 				},
+				Required: []string{"query"},
 			},
 		},
 		{
 			Name:        "__mcp__suggest_tool",
 			Description: "Suggest a child MCP server that would be generically useful for the request and other anticipated requests.",
-			InputSchema: mcp.JSONSchemaObject{
+			InputSchema: mcp.JSONSchema{
 				Type: "object",
-				Properties: map[string]any{
-					"tools": map[string]any{
-						"type": "array",
-						"items": map[string]any{
-							"type": "object",
-							"properties": map[string]any{
-								"name": map[string]any{
-									"type": "string",
+				Properties: map[string]*mcp.JSONSchema{
+					"tools": {
+						Type: "array",
+						Items: &mcp.JSONSchema{
+							Type: "object",
+							Properties: map[string]*mcp.JSONSchema{
+								"name": {
+									Type: "string",
 								},
-								"description": map[string]any{
-									"type": "string",
+								"description": {
+									Type: "string",
 								},
-								"inputSchema": map[string]any{
-									"type":       "object",
-									"properties": true,
+								"inputSchema": {
+									Type: "object",
 								},
 							},
+							Required: []string{"name"},
 						},
 					},
 				},
+				Required: []string{"tools"},
 			},
 		},
 	}
 
+	tools := builtInTools
+	for _, child := range lb.listChildren() {
+		if !child.isHealthy() {
+			continue
+		}
+
+		tools = append(tools, child.namespacedTools()...)
+	}
+
+	page, nextCursor, err := paginate(tools, func(tool mcp.ToolDefinition) string { return tool.Name }, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
 	return &mcp.ToolsListResult{
-		Tools: builtInTools,
+		Tools:      page,
+		NextCursor: nextCursor,
 	}, nil
 }
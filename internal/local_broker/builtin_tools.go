@@ -0,0 +1,273 @@
+package localbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mcp/internal/integrations"
+	"mcp/internal/mcp"
+	"mcp/internal/registry"
+	"mcp/internal/suggestions"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+type registrySearchResult struct {
+	Name            string   `json:"name"`
+	Version         string   `json:"version"`
+	Description     string   `json:"description"`
+	Runtime         string   `json:"runtime"`
+	RequiredEnvVars []string `json:"requiredEnvVars"`
+	Score           float64  `json:"score"`
+}
+
+type registrySearchResults struct {
+	Results []registrySearchResult `json:"results"`
+}
+
+// handleSearchRegistryTool implements the "__mcp__search_registry" tool.
+func (lb *localBroker) handleSearchRegistryTool(ctx context.Context, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+	query, err := stringArgument(req, "query")
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := lb.registry.SearchIntegrations(ctx, query)
+	if err != nil {
+		lb.logger.Error("error searching registry", "query", query, "err", err)
+		return toolErrorResult(fmt.Sprintf("Error searching the registry: %v", err)), nil
+	}
+
+	results := registrySearchResults{
+		Results: make([]registrySearchResult, 0, len(found)),
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d servers matching %q.\n", len(found), query)
+
+	for i, pkg := range found {
+		result := registrySearchResult{
+			Name:            pkg.Name,
+			Version:         pkg.Version,
+			Description:     pkg.Description,
+			Runtime:         pkg.Runtime,
+			RequiredEnvVars: []string{},
+			Score:           pkg.Score,
+		}
+
+		for _, envVar := range pkg.EnvVars {
+			if envVar.Required {
+				result.RequiredEnvVars = append(result.RequiredEnvVars, envVar.Key)
+			}
+		}
+
+		results.Results = append(results.Results, result)
+
+		fmt.Fprintf(&b, "\n%d. %s@%s (runtime: %s)\n", i+1, result.Name, result.Version, result.Runtime)
+		fmt.Fprintf(&b, "   %s\n", result.Description)
+		if len(result.RequiredEnvVars) > 0 {
+			fmt.Fprintf(&b, "   Required environment variables: %s\n", strings.Join(result.RequiredEnvVars, ", "))
+		}
+	}
+
+	return &mcp.ToolsCallResult{
+		Content:           []mcp.Content{mcp.NewTextContent(b.String())},
+		StructuredContent: results,
+	}, nil
+}
+
+type installServerResult struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Tools   []string `json:"tools"`
+}
+
+// handleInstallServerTool implements the "__mcp__install_server" tool.
+func (lb *localBroker) handleInstallServerTool(ctx context.Context, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+	name, err := stringArgument(req, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := stringArgument(req, "version")
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := lb.registry.GetIntegrationManifestByNameAndVersion(name, version)
+	if err != nil {
+		if errors.Is(err, registry.ErrIntegrationNotFound) {
+			return toolErrorResult(fmt.Sprintf("The server %s@%s was not found in the registry. Use %q to find available servers.", name, version, "__mcp__search_registry")), nil
+		}
+
+		lb.logger.Error("error resolving integration manifest", "name", name, "version", version, "err", err)
+		return toolErrorResult(fmt.Sprintf("Error resolving the server %s@%s: %v", name, version, err)), nil
+	}
+
+	installed, err := lb.integRepo.ListIntegrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing integrations: %w", err)
+	}
+
+	for _, integration := range installed {
+		if integration.Manifest.Name == manifest.Name {
+			return toolErrorResult(fmt.Sprintf("The server %s is already installed.", manifest.Name)), nil
+		}
+	}
+
+	env := hostEnv(manifest)
+
+	if missing := integrations.MissingEnvVars(manifest, env); len(missing) > 0 {
+		return toolErrorResult(fmt.Sprintf(
+			"The server %s@%s was NOT installed because it requires configuration that is not available: %s. "+
+				"Ask the user to run `mcp package install %s@%s` in a terminal to provide it, "+
+				"or to set these environment variables for `mcp` and restart it.",
+			manifest.Name, manifest.Version, strings.Join(missing, ", "), manifest.Name, manifest.Version,
+		)), nil
+	}
+
+	integration, err := lb.integRepo.InstallIntegration(ctx, manifest, env)
+	if err != nil {
+		lb.logger.Error("error installing integration", "name", manifest.Name, "version", manifest.Version, "err", err)
+		return toolErrorResult(fmt.Sprintf("Error installing the server %s@%s: %v", manifest.Name, manifest.Version, err)), nil
+	}
+
+	// The child is started here rather than left to the repository's event,
+	// whose handler may not have run yet; startIntegration returns the child
+	// started by whichever comes first.
+	child, err := lb.startIntegration(lb.runCtx, *integration)
+	if err != nil {
+		lb.logger.Error("error starting integration", "id", integration.Id, "err", err)
+		return toolErrorResult(fmt.Sprintf("The server %s@%s was installed but could not be started: %v", manifest.Name, manifest.Version, err)), nil
+	}
+
+	timer := time.NewTimer(lb.integrationStartTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return toolErrorResult(fmt.Sprintf("The server %s@%s was installed but is taking too long to start. Its tools will become available once it is ready.", manifest.Name, manifest.Version)), nil
+	case <-child.done:
+		return toolErrorResult(fmt.Sprintf("The server %s@%s was installed but stopped while starting.", manifest.Name, manifest.Version)), nil
+	case <-child.ready:
+	}
+
+	result := installServerResult{
+		Name:    manifest.Name,
+		Version: manifest.Version,
+		Tools:   []string{},
+	}
+
+	for _, tool := range child.namespacedTools() {
+		result.Tools = append(result.Tools, tool.Name)
+	}
+
+	text := fmt.Sprintf("Installed the server %s@%s. ", manifest.Name, manifest.Version)
+	if len(result.Tools) > 0 {
+		text += fmt.Sprintf("The following tools are now available: %s.", strings.Join(result.Tools, ", "))
+	} else {
+		text += "It does not provide any tools."
+	}
+
+	return &mcp.ToolsCallResult{
+		Content:           []mcp.Content{mcp.NewTextContent(text)},
+		StructuredContent: result,
+	}, nil
+}
+
+// handleSuggestToolTool implements the "__mcp__suggest_tool" tool.
+func (lb *localBroker) handleSuggestToolTool(ctx context.Context, sess *session, req *mcp.ToolsCallRequest) (*mcp.ToolsCallResult, error) {
+	rawTools, ok := req.Arguments["tools"].([]any)
+	if !ok || len(rawTools) == 0 {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("tool %q requires a non-empty array argument %q", req.ToolName, "tools"),
+		}
+	}
+
+	suggested := make([]suggestions.SuggestedTool, 0, len(rawTools))
+
+	for i, rawTool := range rawTools {
+		tool, ok := rawTool.(map[string]any)
+		if !ok {
+			return nil, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: fmt.Sprintf("tools[%d] must be an object", i),
+			}
+		}
+
+		name, _ := tool["name"].(string)
+		if name == "" {
+			return nil, &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeInvalidParams,
+				Message: fmt.Sprintf("tools[%d].name must be a non-empty string", i),
+			}
+		}
+
+		description, _ := tool["description"].(string)
+		inputSchema, _ := tool["inputSchema"].(map[string]any)
+
+		suggested = append(suggested, suggestions.SuggestedTool{
+			Name:        name,
+			Description: description,
+			InputSchema: inputSchema,
+		})
+	}
+
+	if err := lb.suggestions.SuggestTools(ctx, sess.id, suggested); err != nil {
+		lb.logger.Error("error recording tool suggestions", "err", err)
+		return toolErrorResult(fmt.Sprintf("Error recording the suggestion: %v", err)), nil
+	}
+
+	return &mcp.ToolsCallResult{
+		Content: []mcp.Content{mcp.NewTextContent(fmt.Sprintf(
+			"Recorded %d suggested tools. This does NOT change whether the request can be fulfilled.",
+			len(suggested),
+		))},
+	}, nil
+}
+
+// hostEnv collects the values of the environment variables declared by the
+// manifest from the broker's own environment. When the broker runs as the
+// daemon, this is the environment the daemon was started with, so any secrets
+// exported there, such as API tokens, are handed to servers the model installs
+// without the user being asked; servers requiring variables that are not set
+// are refused instead.
+func hostEnv(m *registry.IntegrationManifest) map[string]string {
+	env := make(map[string]string)
+
+	for _, envVar := range m.EnvVars {
+		if value, ok := os.LookupEnv(envVar.Key); ok {
+			env[envVar.Key] = value
+		}
+	}
+
+	return env
+}
+
+// stringArgument extracts a required string argument from a tool call.
+func stringArgument(req *mcp.ToolsCallRequest, name string) (string, error) {
+	value, ok := req.Arguments[name].(string)
+	if !ok || value == "" {
+		return "", &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("tool %q requires the string argument %q", req.ToolName, name),
+		}
+	}
+
+	return value, nil
+}
+
+// toolErrorResult reports a failure that the model should see and be able to
+// react to, as opposed to a protocol error.
+func toolErrorResult(message string) *mcp.ToolsCallResult {
+	return &mcp.ToolsCallResult{
+		Content: []mcp.Content{mcp.NewTextContent(message)},
+		IsError: true,
+	}
+}
@@ -0,0 +1,472 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/integrations"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// childServer tracks a running child MCP server along with the capabilities
+// it advertised.
+type childServer struct {
+	integrationId string
+	prefix        string
+	instance      serverrunner.ServerInstance
+	cancel        context.CancelFunc
+	// remote is set for children hosted elsewhere, which can't see the
+	// clients' roots even when they are mounted.
+	remote bool
+
+	// ready is closed once the child is initialized and its tools are known.
+	ready chan struct{}
+	// done is closed once the child has stopped running.
+	done chan struct{}
+
+	// unhealthy is set while the child isn't responding to pings.
+	unhealthy atomic.Bool
+	// restarting is set once a restart of the child has been requested, the
+	// restarted child being a new childServer.
+	restarting atomic.Bool
+
+	mu      sync.RWMutex
+	conn    serverrunner.ServerConn
+	tools   []mcp.ToolDefinition
+	schemas map[string]*toolSchemas
+
+	// lists caches the prompts, resources and resource templates listed by
+	// the child, by method, generation being bumped whenever they change.
+	lists    map[string]any
+	listsGen uint64
+	listsMu  sync.Mutex
+
+	// callers lists the sessions of the requests to the child in flight, one
+	// entry per request in the order they were issued.
+	callers   []*session
+	callersMu sync.Mutex
+}
+
+// trackCaller records a session's request to the child as in flight. The
+// returned function must be called once the request has completed.
+func (c *childServer) trackCaller(sess *session) func() {
+	c.callersMu.Lock()
+	c.callers = append(c.callers, sess)
+	c.callersMu.Unlock()
+
+	return func() {
+		c.callersMu.Lock()
+		defer c.callersMu.Unlock()
+
+		if i := slices.Index(c.callers, sess); i >= 0 {
+			c.callers = slices.Delete(c.callers, i, i+1)
+		}
+	}
+}
+
+// callerSessions returns the sessions with requests to the child in flight,
+// without duplicates, that of the most recent request first.
+func (c *childServer) callerSessions() []*session {
+	c.callersMu.Lock()
+	defer c.callersMu.Unlock()
+
+	var sessions []*session
+	for i := len(c.callers) - 1; i >= 0; i-- {
+		if !slices.Contains(sessions, c.callers[i]) {
+			sessions = append(sessions, c.callers[i])
+		}
+	}
+
+	return sessions
+}
+
+func (c *childServer) isHealthy() bool {
+	return !c.unhealthy.Load()
+}
+
+// setHealthy records whether the child is responding and reports whether that
+// changed.
+func (c *childServer) setHealthy(healthy bool) bool {
+	return c.unhealthy.Swap(!healthy) == healthy
+}
+
+func (c *childServer) setConn(conn serverrunner.ServerConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn = conn
+}
+
+// getConn returns the child's connection, if the child has completed its
+// initialization handshake.
+func (c *childServer) getConn() (serverrunner.ServerConn, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.conn, c.conn != nil
+}
+
+// readyConn returns the child's connection or a JSON-RPC error suitable for
+// relaying to the client if the child is still starting.
+func (c *childServer) readyConn() (serverrunner.ServerConn, error) {
+	conn, ok := c.getConn()
+	if !ok {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: fmt.Sprintf("server %q is still starting", c.prefix),
+		}
+	}
+
+	return conn, nil
+}
+
+func (c *childServer) cachedList(method string) (any, bool) {
+	c.listsMu.Lock()
+	defer c.listsMu.Unlock()
+
+	items, ok := c.lists[method]
+	return items, ok
+}
+
+func (c *childServer) listsGeneration() uint64 {
+	c.listsMu.Lock()
+	defer c.listsMu.Unlock()
+
+	return c.listsGen
+}
+
+// cacheList caches a list fetched at the given generation, unless the
+// child's lists changed since.
+func (c *childServer) cacheList(method string, generation uint64, items any) {
+	c.listsMu.Lock()
+	defer c.listsMu.Unlock()
+
+	if generation != c.listsGen {
+		return
+	}
+
+	if c.lists == nil {
+		c.lists = make(map[string]any)
+	}
+	c.lists[method] = items
+}
+
+// invalidateLists drops the cached lists fetched with the given methods.
+func (c *childServer) invalidateLists(methods ...string) {
+	c.listsMu.Lock()
+	defer c.listsMu.Unlock()
+
+	c.listsGen++
+	for _, method := range methods {
+		delete(c.lists, method)
+	}
+}
+
+// listTools fetches the tools currently advertised by the child.
+func (c *childServer) listTools(ctx context.Context) ([]mcp.ToolDefinition, error) {
+	conn, ok := c.getConn()
+	if !ok {
+		return nil, fmt.Errorf("server %q is not ready", c.prefix)
+	}
+
+	if conn.InitializeResult().Capabilities.Tools == nil {
+		return nil, nil
+	}
+
+	return listChildPages(ctx, func(ctx context.Context, cursor string) ([]mcp.ToolDefinition, string, error) {
+		var result mcp.ToolsListResult
+		if err := conn.Call(ctx, "tools/list", &mcp.ToolsListRequest{Cursor: cursor}, &result); err != nil {
+			return nil, "", err
+		}
+
+		return result.Tools, result.NextCursor, nil
+	})
+}
+
+func (c *childServer) hasTool(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return slices.ContainsFunc(c.tools, func(tool mcp.ToolDefinition) bool {
+		return tool.Name == name
+	})
+}
+
+func (c *childServer) setTools(tools []mcp.ToolDefinition, schemas map[string]*toolSchemas) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tools = tools
+	c.schemas = schemas
+}
+
+func (c *childServer) toolSchemas(name string) *toolSchemas {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.schemas[name]
+}
+
+// namespacedTools returns the child's tools with their names qualified by the
+// child's prefix.
+func (c *childServer) namespacedTools() []mcp.ToolDefinition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	tools := make([]mcp.ToolDefinition, 0, len(c.tools))
+	for _, tool := range c.tools {
+		tool.Name = namespacedName(c.prefix, tool.Name)
+		tools = append(tools, tool)
+	}
+
+	return tools
+}
+
+// addChild registers a child server for the given integration, assigning it a
+// prefix that is unique among the currently registered children. Since Run
+// starts the installed integrations in the order they were installed, the
+// first one installed keeps the plain prefix when several share a name.
+func (lb *localBroker) addChild(integration integrations.InstalledIntegration, instance serverrunner.ServerInstance, cancel context.CancelFunc) *childServer {
+	lb.childrenMu.Lock()
+	defer lb.childrenMu.Unlock()
+
+	name := integration.Id
+	if integration.Manifest != nil {
+		name = integration.Manifest.Name
+	}
+
+	prefix := uniquePrefix(namespacePrefix(name), integration.Id, func(prefix string) bool {
+		for _, other := range lb.children {
+			if other.prefix == prefix {
+				return true
+			}
+		}
+
+		return false
+	})
+
+	child := &childServer{
+		integrationId: integration.Id,
+		prefix:        prefix,
+		instance:      instance,
+		cancel:        cancel,
+		remote:        isRemote(integration),
+		ready:         make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	lb.children[integration.Id] = child
+
+	return child
+}
+
+func (lb *localBroker) getChild(integrationId string) (*childServer, bool) {
+	lb.childrenMu.RLock()
+	defer lb.childrenMu.RUnlock()
+
+	child, ok := lb.children[integrationId]
+	return child, ok
+}
+
+func (lb *localBroker) getChildByPrefix(prefix string) (*childServer, bool) {
+	lb.childrenMu.RLock()
+	defer lb.childrenMu.RUnlock()
+
+	for _, child := range lb.children {
+		if child.prefix == prefix {
+			return child, true
+		}
+	}
+
+	return nil, false
+}
+
+func (lb *localBroker) deleteChild(integrationId string) {
+	lb.childrenMu.Lock()
+	defer lb.childrenMu.Unlock()
+
+	delete(lb.children, integrationId)
+}
+
+// listChildren returns a snapshot of the registered children ordered by
+// prefix.
+func (lb *localBroker) listChildren() []*childServer {
+	lb.childrenMu.RLock()
+	defer lb.childrenMu.RUnlock()
+
+	children := make([]*childServer, 0, len(lb.children))
+	for _, child := range lb.children {
+		children = append(children, child)
+	}
+
+	slices.SortFunc(children, func(a, b *childServer) int {
+		return strings.Compare(a.prefix, b.prefix)
+	})
+
+	return children
+}
+
+// handleChildRequest handles the requests and notifications sent by the child
+// server registered for the given integration.
+func (lb *localBroker) handleChildRequest(ctx context.Context, integrationId string, req *jsonrpc2.Request) (interface{}, error) {
+	child, ok := lb.getChild(integrationId)
+	if !ok {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: "server is not registered",
+		}
+	}
+
+	lb.logger.Debug("handling child request", "prefix", child.prefix, "method", req.Method)
+
+	if !req.Notif {
+		var done func()
+		ctx, done = lb.inflight.track(ctx, child.integrationId, req.ID)
+		defer done()
+	}
+
+	switch req.Method {
+	case NOTIFICATION_CANCELLED:
+		req, err := mcp.MustParams[mcp.CancelledNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		lb.handleCancelledNotification(lb.inflight, child.integrationId, req)
+		return nil, nil
+	case NOTIFICATION_PROGRESS:
+		req, err := mcp.MustParams[mcp.ProgressNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lb.handleChildProgressNotification(ctx, child, req)
+	case "notifications/resources/updated":
+		req, err := mcp.MustParams[mcp.ResourceUpdatedNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lb.handleChildResourceUpdatedNotification(ctx, child, req)
+	case "sampling/createMessage":
+		params, err := mcp.MustParams[mcp.CreateMessageRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleChildSamplingRequest(ctx, child, req.ID, params)
+	case NOTIFICATION_MESSAGE:
+		req, err := mcp.MustParams[mcp.LoggingMessageNotification](req)
+		if err != nil {
+			return nil, err
+		}
+		return nil, lb.handleChildLoggingMessageNotification(ctx, child, req)
+	case "roots/list":
+		params, err := mcp.OptionalParams[mcp.RootsListRequest](req)
+		if err != nil {
+			return nil, err
+		}
+		return lb.handleChildRootsListRequest(ctx, child, params)
+	case NOTIFICATION_TOOLS_LIST_CHANGED:
+		return nil, lb.handleChildToolsListChangedNotification(ctx, child)
+	case NOTIFICATION_PROMPTS_LIST_CHANGED:
+		child.invalidateLists("prompts/list")
+		lb.notifyListChanged(ctx, req.Method)
+		return nil, nil
+	case NOTIFICATION_RESOURCES_LIST_CHANGED:
+		child.invalidateLists("resources/list", "resources/templates/list")
+		lb.notifyListChanged(ctx, req.Method)
+		return nil, nil
+	default:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method %q not found", req.Method),
+		}
+	}
+}
+
+// handleChildToolsListChangedNotification refreshes the child's cached tools
+// before letting the client know that the aggregated list changed.
+func (lb *localBroker) handleChildToolsListChangedNotification(ctx context.Context, child *childServer) error {
+	tools, err := child.listTools(ctx)
+	if err != nil {
+		return fmt.Errorf("error refreshing tools for server %q: %w", child.prefix, err)
+	}
+
+	lb.setChildTools(child, tools)
+	lb.notifyListChanged(ctx, NOTIFICATION_TOOLS_LIST_CHANGED)
+
+	return nil
+}
+
+// fanOut calls fn concurrently for every child that has completed its
+// initialization handshake and concatenates the results in prefix order. The
+// result is never nil so that it serializes as a JSON array.
+//
+// Errors are logged and otherwise ignored so that a single misbehaving child
+// can't break the aggregated view of the others.
+func fanOut[T any](ctx context.Context, lb *localBroker, fn func(ctx context.Context, child *childServer, conn serverrunner.ServerConn) ([]T, error)) []T {
+	children := lb.listChildren()
+	results := make([][]T, len(children))
+
+	var wg sync.WaitGroup
+	for i, child := range children {
+		conn, ok := child.getConn()
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			items, err := fn(ctx, child, conn)
+			if err != nil {
+				lb.logger.Error("error calling child server", "prefix", child.prefix, "err", err)
+				return
+			}
+
+			results[i] = items
+		}()
+	}
+	wg.Wait()
+
+	if items := slices.Concat(results...); items != nil {
+		return items
+	}
+
+	return []T{}
+}
+
+// childCallError converts an error returned while calling a child server into
+// a JSON-RPC error suitable for relaying to the client.
+func childCallError(prefix string, err error) *jsonrpc2.Error {
+	if rpcErr, ok := err.(*jsonrpc2.Error); ok {
+		return rpcErr
+	}
+
+	if err == jsonrpc2.ErrClosed {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: fmt.Sprintf("server %q is not running", prefix),
+		}
+	}
+
+	return &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInternalError,
+		Message: fmt.Sprintf("error calling server %q: %v", prefix, err),
+	}
+}
+
+// isRemote reports whether the integration's server is reached over HTTP
+// rather than run in a container.
+func isRemote(integration integrations.InstalledIntegration) bool {
+	if integration.Manifest == nil {
+		return false
+	}
+
+	runtime, err := serverrunner.ParseRuntime(integration.Manifest.Runtime)
+	return err == nil && runtime.IsRemote()
+}
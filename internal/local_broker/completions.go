@@ -0,0 +1,115 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// supportsCompletions reports whether a child accepts completion/complete
+// requests. Servers speaking revisions older than 2025-03-26 had no way of
+// declaring it so they are assumed to support it for their prompts and
+// resources.
+func supportsCompletions(conn serverrunner.ServerConn) bool {
+	initializeResult := conn.InitializeResult()
+	if initializeResult.Capabilities.Completions != nil {
+		return true
+	}
+
+	if mcp.ProtocolVersionAtLeast(initializeResult.ProtocolVersion, mcp.PROTOCOL_VERSION_2025_03_26) {
+		return false
+	}
+
+	return initializeResult.Capabilities.Prompts != nil || initializeResult.Capabilities.Resources != nil
+}
+
+// anyChildSupportsCompletions reports whether at least one ready child
+// accepts completion/complete requests.
+func (lb *localBroker) anyChildSupportsCompletions() bool {
+	for _, child := range lb.listChildren() {
+		if conn, ok := child.getConn(); ok && supportsCompletions(conn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (lb *localBroker) handleCompleteRequest(ctx context.Context, sess *session, req *mcp.CompleteRequest) (*mcp.CompleteResult, error) {
+	if !sess.completions.Load() {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: "completions were not offered to this client",
+		}
+	}
+
+	ref := req.Ref
+
+	var prefix string
+	var ok bool
+
+	switch ref.Type {
+	case mcp.CompletionRefPrompt:
+		prefix, ref.Name, ok = splitNamespacedName(ref.Name)
+	case mcp.CompletionRefResource:
+		prefix, ref.URI, ok = splitNamespacedURI(ref.URI)
+	default:
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("unknown reference type %q", ref.Type),
+		}
+	}
+
+	refName := req.Ref.Name
+	if ref.Type == mcp.CompletionRefResource {
+		refName = req.Ref.URI
+	}
+
+	errRefNotFound := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: fmt.Sprintf("%s %q not found", ref.Type, refName),
+	}
+
+	if !ok {
+		return nil, errRefNotFound
+	}
+
+	child, ok := lb.getChildByPrefix(prefix)
+	if !ok {
+		return nil, errRefNotFound
+	}
+
+	conn, err := child.readyConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if !supportsCompletions(conn) {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("server %q does not support completions", prefix),
+		}
+	}
+
+	meta, release := lb.progress.forward(sess, req.Meta)
+	defer release()
+
+	var result mcp.CompleteResult
+	if err := lb.callChild(ctx, sess, child, conn, "completion/complete", &mcp.CompleteRequest{
+		Meta:     meta,
+		Ref:      ref,
+		Argument: req.Argument,
+		Context:  req.Context,
+	}, &result); err != nil {
+		return nil, childCallError(prefix, err)
+	}
+
+	if result.Completion.Values == nil {
+		result.Completion.Values = []string{}
+	}
+
+	return &result, nil
+}
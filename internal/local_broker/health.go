@@ -0,0 +1,176 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"time"
+)
+
+const (
+	DEFAULT_PING_INTERVAL_SECONDS = 30
+	DEFAULT_PING_TIMEOUT_SECONDS  = 10
+
+	// UNHEALTHY_RESTART_THRESHOLD is the number of consecutive failed pings
+	// after which an unhealthy child is restarted, if the restart policy
+	// allows it.
+	UNHEALTHY_RESTART_THRESHOLD = 3
+
+	// MAX_CRASH_RESTART_DELAY_SECONDS caps the delay before restarting a child
+	// that exited or disconnected, which doubles from one second with each
+	// crash until the child answers a ping.
+	MAX_CRASH_RESTART_DELAY_SECONDS = 60
+)
+
+// RestartPolicy determines what the broker does about children that stop
+// responding, exit or disconnect.
+type RestartPolicy string
+
+const (
+	// RestartPolicyNever leaves unhealthy children running, their tools hidden
+	// until they respond again, and children that exit stopped.
+	RestartPolicyNever RestartPolicy = "never"
+	// RestartPolicyOnUnhealthy restarts children that exit, disconnect or fail
+	// UNHEALTHY_RESTART_THRESHOLD pings in a row.
+	RestartPolicyOnUnhealthy RestartPolicy = "on-unhealthy"
+)
+
+// ParseRestartPolicy validates a restart policy, defaulting to
+// RestartPolicyOnUnhealthy when s is empty.
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch policy := RestartPolicy(s); policy {
+	case "":
+		return RestartPolicyOnUnhealthy, nil
+	case RestartPolicyNever, RestartPolicyOnUnhealthy:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown restart policy %q", s)
+	}
+}
+
+// monitorChild pings a ready child until ctx is cancelled, marking it
+// unhealthy while it doesn't respond and restarting it according to the
+// broker's restart policy.
+func (lb *localBroker) monitorChild(ctx context.Context, child *childServer, conn serverrunner.ServerConn) error {
+	ticker := time.NewTicker(time.Duration(DEFAULT_PING_INTERVAL_SECONDS) * time.Second)
+	defer ticker.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-conn.DisconnectNotify():
+			return fmt.Errorf("connection to server %q lost", child.prefix)
+		case <-ticker.C:
+		}
+
+		if err := pingChild(ctx, conn); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			failures++
+			lb.logger.Warn("integration did not respond to ping", "id", child.integrationId, "prefix", child.prefix, "failures", failures, "err", err)
+
+			if child.setHealthy(false) {
+				lb.notifyListChanged(ctx, NOTIFICATION_TOOLS_LIST_CHANGED)
+			}
+
+			if lb.options.RestartPolicy == RestartPolicyOnUnhealthy && failures >= UNHEALTHY_RESTART_THRESHOLD {
+				go lb.restartChild(child)
+				return nil
+			}
+
+			continue
+		}
+
+		failures = 0
+		lb.resetCrashes(child.integrationId)
+
+		if child.setHealthy(true) {
+			lb.logger.Info("integration is responding again", "id", child.integrationId, "prefix", child.prefix)
+			lb.notifyListChanged(ctx, NOTIFICATION_TOOLS_LIST_CHANGED)
+		}
+	}
+}
+
+func pingChild(ctx context.Context, conn serverrunner.ServerConn) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(DEFAULT_PING_TIMEOUT_SECONDS)*time.Second)
+	defer cancel()
+
+	return conn.Call(ctx, "ping", nil, &mcp.EmptyResult{})
+}
+
+// restartCrashedChild restarts a child that exited or disconnected on its
+// own, after a delay growing with its consecutive crashes so that a server
+// failing at startup isn't restarted in a tight loop.
+func (lb *localBroker) restartCrashedChild(child *childServer) {
+	if lb.options.RestartPolicy != RestartPolicyOnUnhealthy {
+		return
+	}
+
+	lb.crashesMu.Lock()
+	crashes := lb.crashes[child.integrationId]
+	lb.crashes[child.integrationId] = crashes + 1
+	lb.crashesMu.Unlock()
+
+	// The shift is bounded so that the delay cannot overflow.
+	delay := min(time.Second<<min(crashes, 10), time.Duration(MAX_CRASH_RESTART_DELAY_SECONDS)*time.Second)
+
+	lb.logger.Info("integration stopped unexpectedly", "id", child.integrationId, "crashes", crashes+1, "restartIn", delay)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-lb.runCtx.Done():
+		return
+	case <-timer.C:
+	}
+
+	lb.restartChild(child)
+}
+
+// resetCrashes forgets the crashes of a child that is responding again.
+func (lb *localBroker) resetCrashes(integrationId string) {
+	lb.crashesMu.Lock()
+	defer lb.crashesMu.Unlock()
+
+	delete(lb.crashes, integrationId)
+}
+
+// restartChild stops a child and starts it again once it has exited, unless
+// its integration was uninstalled in the meantime. Concurrent requests to
+// restart the same child, say from a roots change racing the health monitor,
+// restart it once.
+func (lb *localBroker) restartChild(child *childServer) {
+	if !child.restarting.CompareAndSwap(false, true) {
+		return
+	}
+
+	lb.logger.Info("restarting integration", "id", child.integrationId)
+
+	child.cancel()
+	<-child.done
+
+	installed, err := lb.integRepo.ListIntegrations(lb.runCtx)
+	if err != nil {
+		lb.logger.Error("error listing integrations", "err", err)
+		return
+	}
+
+	for _, integration := range installed {
+		if integration.Id != child.integrationId {
+			continue
+		}
+
+		if _, err := lb.startIntegration(lb.runCtx, *integration); err != nil {
+			lb.logger.Error("error restarting integration", "id", child.integrationId, "err", err)
+		}
+
+		return
+	}
+}
@@ -0,0 +1,180 @@
+package localbroker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const NOTIFICATION_MESSAGE = "notifications/message"
+
+// DEFAULT_CLIENT_LOGGING_LEVEL is the minimum level of the log messages
+// relayed to the client until it asks for another one.
+const DEFAULT_CLIENT_LOGGING_LEVEL = mcp.LoggingLevelInfo
+
+// CHILD_STDERR_LOGGING_LEVEL is the level at which the lines a child writes to
+// stderr are relayed to the client.
+const CHILD_STDERR_LOGGING_LEVEL = mcp.LoggingLevelInfo
+
+// MAX_CHILD_STDERR_LINE_BYTES bounds the part of a line written by a child to
+// stderr that is buffered while waiting for its end. Longer lines are relayed
+// in several messages.
+const MAX_CHILD_STDERR_LINE_BYTES = 64 << 10
+
+// childLoggingLevel returns the most verbose level asked for by any client,
+// which is the level children are asked to log at since they are shared. The
+// zero value means no client asked for one.
+func (lb *localBroker) childLoggingLevel() mcp.LoggingLevel {
+	var level mcp.LoggingLevel
+
+	for _, sess := range lb.listSessions() {
+		sessLevel := sess.getLoggingLevel()
+		if sessLevel != "" && (level == "" || sessLevel.Severity() < level.Severity()) {
+			level = sessLevel
+		}
+	}
+
+	return level
+}
+
+func (lb *localBroker) handleLoggingSetLevelRequest(ctx context.Context, sess *session, req *mcp.LoggingSetLevelRequest) (*mcp.EmptyResult, error) {
+	if req.Level.Severity() < 0 {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("unknown logging level %q", req.Level),
+		}
+	}
+
+	sess.setLoggingLevel(req.Level)
+
+	sess.logger.Info("client set logging level", "level", req.Level)
+
+	// The level only filters the messages relayed to this client. The
+	// broker's own logs are left alone since it serves other clients too.
+	level := lb.childLoggingLevel()
+
+	for _, child := range lb.listChildren() {
+		conn, ok := child.getConn()
+		if !ok {
+			continue
+		}
+
+		lb.forwardLoggingLevel(ctx, child, conn, level)
+	}
+
+	return &mcp.EmptyResult{}, nil
+}
+
+// forwardLoggingLevel passes the logging level requested by the clients on
+// to a child that supports logging.
+func (lb *localBroker) forwardLoggingLevel(ctx context.Context, child *childServer, conn serverrunner.ServerConn, level mcp.LoggingLevel) {
+	if conn.InitializeResult().Capabilities.Logging == nil {
+		return
+	}
+
+	if err := conn.Call(ctx, "logging/setLevel", &mcp.LoggingSetLevelRequest{
+		Level: level,
+	}, nil); err != nil {
+		lb.logger.Error("error setting child logging level", "prefix", child.prefix, "err", err)
+	}
+}
+
+// handleChildLoggingMessageNotification relays a child's log message to the
+// clients, using the child's prefix as the logger name.
+func (lb *localBroker) handleChildLoggingMessageNotification(ctx context.Context, child *childServer, n *mcp.LoggingMessageNotification) error {
+	return lb.notifyLoggingMessage(ctx, &mcp.LoggingMessageNotification{
+		Level:  n.Level,
+		Logger: child.prefix,
+		Data:   n.Data,
+	})
+}
+
+// notifyLoggingMessage sends a log message to every client that asked for
+// messages at its level or below.
+func (lb *localBroker) notifyLoggingMessage(ctx context.Context, n *mcp.LoggingMessageNotification) error {
+	var result error
+
+	for _, sess := range lb.listInitializedSessions() {
+		level := sess.getLoggingLevel()
+		if level == "" {
+			level = DEFAULT_CLIENT_LOGGING_LEVEL
+		}
+
+		if n.Level.Severity() < level.Severity() {
+			continue
+		}
+
+		if err := sess.conn.Notify(ctx, NOTIFICATION_MESSAGE, n); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// childStderr returns a writer relaying each line the child registered for
+// the given integration writes to stderr as a log message.
+func (lb *localBroker) childStderr(integrationId string) io.Writer {
+	return &childStderrWriter{
+		relay: func(line string) {
+			child, ok := lb.getChild(integrationId)
+			if !ok {
+				return
+			}
+
+			if err := lb.notifyLoggingMessage(lb.runCtx, &mcp.LoggingMessageNotification{
+				Level:  CHILD_STDERR_LOGGING_LEVEL,
+				Logger: child.prefix,
+				Data:   line,
+			}); err != nil {
+				// Failing the write would stop the child's output from being
+				// read altogether.
+				lb.logger.Error("error relaying child stderr", "prefix", child.prefix, "err", err)
+			}
+		},
+	}
+}
+
+// childStderrWriter splits what a child writes to stderr into lines, which
+// may span several writes, and relays each non-blank one.
+type childStderrWriter struct {
+	relay   func(line string)
+	partial []byte
+}
+
+func (w *childStderrWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+
+		w.relayLine(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+
+	if len(w.partial) >= MAX_CHILD_STDERR_LINE_BYTES {
+		w.relayLine(w.partial)
+		w.partial = nil
+	}
+
+	// Keep the partial line in a buffer of its own rather than pinning
+	// everything written before it.
+	w.partial = bytes.Clone(w.partial)
+
+	return len(p), nil
+}
+
+func (w *childStderrWriter) relayLine(line []byte) {
+	if line = bytes.TrimSpace(line); len(line) > 0 {
+		w.relay(string(line))
+	}
+}
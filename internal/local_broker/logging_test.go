@@ -0,0 +1,65 @@
+package localbroker
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChildStderrWriter(t *testing.T) {
+	long := strings.Repeat("x", MAX_CHILD_STDERR_LINE_BYTES)
+
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "whole lines",
+			writes: []string{"first\nsecond\n"},
+			want:   []string{"first", "second"},
+		},
+		{
+			name:   "line split across writes",
+			writes: []string{"fir", "st\nsec", "ond", "\n"},
+			want:   []string{"first", "second"},
+		},
+		{
+			name:   "unterminated line is held back",
+			writes: []string{"first\nsec"},
+			want:   []string{"first"},
+		},
+		{
+			name:   "blank lines and surrounding spaces",
+			writes: []string{"\n  first \r\n\n", "   \n"},
+			want:   []string{"first"},
+		},
+		{
+			name:   "overlong line is relayed once the buffer is full",
+			writes: []string{long[:10], long[10:], "tail\n"},
+			want:   []string{long, "tail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			w := &childStderrWriter{
+				relay: func(line string) {
+					got = append(got, line)
+				},
+			}
+
+			for _, write := range tt.writes {
+				n, err := w.Write([]byte(write))
+				if err != nil || n != len(write) {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", write, n, err, len(write))
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("relayed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package localbroker
+
+import (
+	"fmt"
+	"mcp/internal/mcp"
+	"path"
+	"strings"
+)
+
+// NAMESPACE_SEPARATOR separates a child server's prefix from the name of a
+// capability it exposes.
+const NAMESPACE_SEPARATOR = "__"
+
+// NAMESPACE_URI_SCHEME is the scheme of the URIs under which the broker
+// exposes the resources of its child servers.
+const NAMESPACE_URI_SCHEME = "mcp"
+
+// namespacePrefix derives a prefix for a child server from its package name.
+//
+// The prefix is made of lowercase alphanumerics separated by single
+// underscores so that it never contains NAMESPACE_SEPARATOR itself. For
+// example, "@modelcontextprotocol/server-github" becomes "server_github".
+func namespacePrefix(name string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(path.Base(name)) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			if s := b.String(); len(s) > 0 && !strings.HasSuffix(s, "_") {
+				b.WriteRune('_')
+			}
+		}
+	}
+
+	prefix := strings.TrimSuffix(b.String(), "_")
+	if prefix == "" {
+		return "server"
+	}
+
+	return prefix
+}
+
+// uniquePrefix returns the first of prefix, then prefix qualified with the
+// integration's id, then numbered variants of the latter, that isn't taken.
+func uniquePrefix(prefix string, integrationId string, taken func(prefix string) bool) string {
+	if !taken(prefix) {
+		return prefix
+	}
+
+	qualified := namespacePrefix(prefix + "_" + integrationId)
+	candidate := qualified
+	for i := 2; taken(candidate); i++ {
+		candidate = fmt.Sprintf("%s_%d", qualified, i)
+	}
+
+	return candidate
+}
+
+// namespacedName qualifies a child server's capability name with its prefix.
+func namespacedName(prefix, name string) string {
+	return prefix + NAMESPACE_SEPARATOR + name
+}
+
+// splitNamespacedName splits a name produced by namespacedName back into the
+// child server's prefix and the original name.
+func splitNamespacedName(namespaced string) (prefix string, name string, ok bool) {
+	prefix, name, ok = strings.Cut(namespaced, NAMESPACE_SEPARATOR)
+	if !ok || prefix == "" || name == "" {
+		return "", "", false
+	}
+
+	return prefix, name, true
+}
+
+// namespacedURI rewrites a child server's resource URI (or URI template) into
+// the broker's namespace, e.g. "file:///a.txt" becomes
+// "mcp://server_filesystem/file:///a.txt".
+func namespacedURI(prefix, uri string) string {
+	return NAMESPACE_URI_SCHEME + "://" + prefix + "/" + uri
+}
+
+// splitNamespacedURI splits a URI produced by namespacedURI back into the
+// child server's prefix and the original URI.
+func splitNamespacedURI(namespaced string) (prefix string, uri string, ok bool) {
+	rest, ok := strings.CutPrefix(namespaced, NAMESPACE_URI_SCHEME+"://")
+	if !ok {
+		return "", "", false
+	}
+
+	prefix, uri, ok = strings.Cut(rest, "/")
+	if !ok || prefix == "" || uri == "" {
+		return "", "", false
+	}
+
+	return prefix, uri, true
+}
+
+// namespaceContentURI rewrites the URI of a resource linked or embedded in
+// content returned by a child server into the broker's namespace, so that the
+// client can read it like those listed by resources/list.
+func namespaceContentURI(prefix string, content mcp.Content) {
+	switch {
+	case content.Resource != nil:
+		content.Resource.Resource.URI = namespacedURI(prefix, content.Resource.Resource.URI)
+	case content.ResourceLink != nil:
+		content.ResourceLink.URI = namespacedURI(prefix, content.ResourceLink.URI)
+	}
+}
@@ -0,0 +1,187 @@
+package localbroker
+
+import (
+	"mcp/internal/mcp"
+	"slices"
+	"testing"
+)
+
+func TestNamespacePrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "server-github", want: "server_github"},
+		{name: "@modelcontextprotocol/server-github", want: "server_github"},
+		{name: "Server.GitHub", want: "server_github"},
+		{name: "my__server", want: "my_server"},
+		{name: "--server--", want: "server"},
+		{name: "server2", want: "server2"},
+		{name: "@scope/", want: "scope"},
+		{name: "---", want: "server"},
+		{name: "", want: "server"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := namespacePrefix(tt.name); got != tt.want {
+				t.Errorf("namespacePrefix(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUniquePrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		id     string
+		taken  []string
+		want   string
+	}{
+		{
+			name:   "free",
+			prefix: "github",
+			id:     "2",
+			want:   "github",
+		},
+		{
+			name:   "taken",
+			prefix: "github",
+			id:     "2",
+			taken:  []string{"github"},
+			want:   "github_2",
+		},
+		{
+			name:   "qualified prefix taken by another server's name",
+			prefix: "github",
+			id:     "2",
+			taken:  []string{"github", "github_2"},
+			want:   "github_2_2",
+		},
+		{
+			name:   "several variants taken",
+			prefix: "github",
+			id:     "2",
+			taken:  []string{"github", "github_2", "github_2_2", "github_2_3"},
+			want:   "github_2_4",
+		},
+		{
+			name:   "id is normalized",
+			prefix: "github",
+			id:     "A-B",
+			taken:  []string{"github"},
+			want:   "github_a_b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := uniquePrefix(tt.prefix, tt.id, func(prefix string) bool {
+				return slices.Contains(tt.taken, prefix)
+			})
+			if got != tt.want {
+				t.Errorf("uniquePrefix(%q, %q) = %q, want %q", tt.prefix, tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitNamespacedName(t *testing.T) {
+	tests := []struct {
+		namespaced string
+		wantPrefix string
+		wantName   string
+		wantOk     bool
+	}{
+		{namespaced: "github__create_issue", wantPrefix: "github", wantName: "create_issue", wantOk: true},
+		{namespaced: "github__create__issue", wantPrefix: "github", wantName: "create__issue", wantOk: true},
+		{namespaced: "github___private", wantPrefix: "github", wantName: "_private", wantOk: true},
+		{namespaced: "create_issue"},
+		{namespaced: "__create_issue"},
+		{namespaced: "github__"},
+		{namespaced: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.namespaced, func(t *testing.T) {
+			prefix, name, ok := splitNamespacedName(tt.namespaced)
+			if prefix != tt.wantPrefix || name != tt.wantName || ok != tt.wantOk {
+				t.Errorf("splitNamespacedName(%q) = %q, %q, %v, want %q, %q, %v", tt.namespaced, prefix, name, ok, tt.wantPrefix, tt.wantName, tt.wantOk)
+			}
+
+			if ok && namespacedName(prefix, name) != tt.namespaced {
+				t.Errorf("namespacedName(%q, %q) = %q, want %q", prefix, name, namespacedName(prefix, name), tt.namespaced)
+			}
+		})
+	}
+}
+
+func TestSplitNamespacedURI(t *testing.T) {
+	tests := []struct {
+		namespaced string
+		wantPrefix string
+		wantURI    string
+		wantOk     bool
+	}{
+		{namespaced: "mcp://filesystem/file:///a.txt", wantPrefix: "filesystem", wantURI: "file:///a.txt", wantOk: true},
+		{namespaced: "mcp://github/repo://owner/name/{path}", wantPrefix: "github", wantURI: "repo://owner/name/{path}", wantOk: true},
+		{namespaced: "mcp://filesystem/mcp://other/x", wantPrefix: "filesystem", wantURI: "mcp://other/x", wantOk: true},
+		{namespaced: "file:///a.txt"},
+		{namespaced: "mcp://filesystem"},
+		{namespaced: "mcp://filesystem/"},
+		{namespaced: "mcp:///file:///a.txt"},
+		{namespaced: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.namespaced, func(t *testing.T) {
+			prefix, uri, ok := splitNamespacedURI(tt.namespaced)
+			if prefix != tt.wantPrefix || uri != tt.wantURI || ok != tt.wantOk {
+				t.Errorf("splitNamespacedURI(%q) = %q, %q, %v, want %q, %q, %v", tt.namespaced, prefix, uri, ok, tt.wantPrefix, tt.wantURI, tt.wantOk)
+			}
+
+			if ok && namespacedURI(prefix, uri) != tt.namespaced {
+				t.Errorf("namespacedURI(%q, %q) = %q, want %q", prefix, uri, namespacedURI(prefix, uri), tt.namespaced)
+			}
+		})
+	}
+}
+
+func TestNamespaceContentURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		content mcp.Content
+		uri     func(mcp.Content) string
+		want    string
+	}{
+		{
+			name:    "resource link",
+			content: mcp.Content{ResourceLink: &mcp.ResourceLink{Type: mcp.ContentTypeResourceLink, URI: "file:///a.txt"}},
+			uri:     func(c mcp.Content) string { return c.ResourceLink.URI },
+			want:    "mcp://filesystem/file:///a.txt",
+		},
+		{
+			name:    "embedded resource",
+			content: mcp.Content{Resource: &mcp.EmbeddedResource{Type: mcp.ContentTypeResource, Resource: mcp.ResourceContents{URI: "file:///a.txt"}}},
+			uri:     func(c mcp.Content) string { return c.Resource.Resource.URI },
+			want:    "mcp://filesystem/file:///a.txt",
+		},
+		{
+			name:    "text",
+			content: mcp.NewTextContent("file:///a.txt"),
+			uri:     func(c mcp.Content) string { return c.Text.Text },
+			want:    "file:///a.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespaceContentURI("filesystem", tt.content)
+
+			if got := tt.uri(tt.content); got != tt.want {
+				t.Errorf("URI = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
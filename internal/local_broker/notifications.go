@@ -0,0 +1,45 @@
+package localbroker
+
+import (
+	"context"
+	"mcp/internal/mcp"
+)
+
+const (
+	NOTIFICATION_TOOLS_LIST_CHANGED     = "notifications/tools/list_changed"
+	NOTIFICATION_PROMPTS_LIST_CHANGED   = "notifications/prompts/list_changed"
+	NOTIFICATION_RESOURCES_LIST_CHANGED = "notifications/resources/list_changed"
+)
+
+// listChangedNotifications returns the list_changed notifications affected by
+// a child server with the given capabilities joining or leaving the broker.
+func listChangedNotifications(capabilities mcp.ServerCapabilities) []string {
+	var methods []string
+
+	if capabilities.Tools != nil {
+		methods = append(methods, NOTIFICATION_TOOLS_LIST_CHANGED)
+	}
+
+	if capabilities.Prompts != nil {
+		methods = append(methods, NOTIFICATION_PROMPTS_LIST_CHANGED)
+	}
+
+	if capabilities.Resources != nil {
+		methods = append(methods, NOTIFICATION_RESOURCES_LIST_CHANGED)
+	}
+
+	return methods
+}
+
+// notifyListChanged sends the given list_changed notifications to every
+// client. Clients that haven't completed initialization are skipped since
+// they will list everything afresh at that point anyway.
+func (lb *localBroker) notifyListChanged(ctx context.Context, methods ...string) {
+	for _, sess := range lb.listInitializedSessions() {
+		for _, method := range methods {
+			if err := sess.conn.Notify(ctx, method, &mcp.ListChangedNotification{}); err != nil {
+				sess.logger.Error("error sending notification", "method", method, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package localbroker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	// DEFAULT_PAGE_SIZE is the number of items returned per page of the
+	// aggregated lists served to the client.
+	DEFAULT_PAGE_SIZE = 100
+
+	// MAX_CHILD_PAGES bounds the number of pages fetched from a child for a
+	// single list, protecting the broker from children whose cursors never
+	// run out.
+	MAX_CHILD_PAGES = 100
+)
+
+// listCursor is the content of the opaque cursors handed to the client. Items
+// are served ordered by their namespaced key, so remembering the last key
+// served keeps the cursor valid as children come and go. Skip counts the items
+// with that key already served, in case a child lists the same key twice.
+type listCursor struct {
+	After string `json:"after"`
+	Skip  int    `json:"skip"`
+}
+
+func encodeCursor(c listCursor) string {
+	b, _ := json.Marshal(&c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(cursor string) (listCursor, error) {
+	if cursor == "" {
+		return listCursor{}, nil
+	}
+
+	errInvalidCursor := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: fmt.Sprintf("invalid cursor %q", cursor),
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return listCursor{}, errInvalidCursor
+	}
+
+	var c listCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.After == "" || c.Skip < 1 {
+		return listCursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+// paginate orders items by key and returns the page following cursor along
+// with the cursor of the next page, if any.
+func paginate[T any](items []T, key func(T) string, cursor string) ([]T, string, error) {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	slices.SortStableFunc(items, func(a, b T) int {
+		return strings.Compare(key(a), key(b))
+	})
+
+	start := 0
+	if c.After != "" {
+		first := sort.Search(len(items), func(i int) bool {
+			return key(items[i]) >= c.After
+		})
+		last := sort.Search(len(items), func(i int) bool {
+			return key(items[i]) > c.After
+		})
+		start = min(first+c.Skip, last)
+	}
+
+	end := min(start+DEFAULT_PAGE_SIZE, len(items))
+	page := items[start:end]
+
+	var nextCursor string
+	if end < len(items) {
+		next := listCursor{After: key(items[end-1])}
+		for i := end - 1; i >= 0 && key(items[i]) == next.After; i-- {
+			next.Skip++
+		}
+
+		nextCursor = encodeCursor(next)
+	}
+
+	return page, nextCursor, nil
+}
+
+// cachedChildList returns one of a child's lists, calling fetch unless it is
+// cached. When the child notifies changes to the list, it is cached until it
+// does. Otherwise, it is only cached for the pages following the first one,
+// each listing fetching it again.
+func cachedChildList[T any](ctx context.Context, child *childServer, method string, listChanged bool, cursor string, fetch func(ctx context.Context) ([]T, error)) ([]T, error) {
+	if listChanged || cursor != "" {
+		if items, ok := child.cachedList(method); ok {
+			return items.([]T), nil
+		}
+	}
+
+	generation := child.listsGeneration()
+
+	items, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	child.cacheList(method, generation, items)
+
+	return items, nil
+}
+
+// listChildPages fetches every page of one of a child's lists.
+func listChildPages[T any](ctx context.Context, fetch func(ctx context.Context, cursor string) ([]T, string, error)) ([]T, error) {
+	var items []T
+	var cursor string
+
+	for range MAX_CHILD_PAGES {
+		page, nextCursor, err := fetch(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, page...)
+
+		if nextCursor == "" {
+			return items, nil
+		}
+
+		cursor = nextCursor
+	}
+
+	return nil, fmt.Errorf("more than %d pages", MAX_CHILD_PAGES)
+}
@@ -0,0 +1,283 @@
+package localbroker
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+type item struct {
+	key   string
+	value int
+}
+
+func itemKey(i item) string {
+	return i.key
+}
+
+// items returns n items keyed "key000", "key001", ... in reverse order, so
+// that paginate has to sort them.
+func items(n int) []item {
+	result := make([]item, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		result = append(result, item{key: fmt.Sprintf("key%03d", i), value: i})
+	}
+
+	return result
+}
+
+// paginateAll follows the cursors returned by paginate until the last page,
+// returning the values served and the size of each page.
+func paginateAll(t *testing.T, all []item) ([]int, []int) {
+	t.Helper()
+
+	var values, pageSizes []int
+	var cursor string
+
+	for {
+		page, nextCursor, err := paginate(slices.Clone(all), itemKey, cursor)
+		if err != nil {
+			t.Fatalf("paginate(%q) error = %v", cursor, err)
+		}
+
+		pageSizes = append(pageSizes, len(page))
+		for _, i := range page {
+			values = append(values, i.value)
+		}
+
+		if nextCursor == "" {
+			return values, pageSizes
+		}
+
+		if len(pageSizes) > len(all) {
+			t.Fatalf("paginate never returned the last page")
+		}
+
+		cursor = nextCursor
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	duplicated := items(DEFAULT_PAGE_SIZE - 1)
+	for i := range 3 {
+		duplicated = append(duplicated, item{key: "key098", value: 1000 + i})
+	}
+
+	tests := []struct {
+		name          string
+		items         []item
+		wantPageSizes []int
+	}{
+		{
+			name:          "empty",
+			items:         nil,
+			wantPageSizes: []int{0},
+		},
+		{
+			name:          "single page",
+			items:         items(3),
+			wantPageSizes: []int{3},
+		},
+		{
+			name:          "exactly one page",
+			items:         items(DEFAULT_PAGE_SIZE),
+			wantPageSizes: []int{DEFAULT_PAGE_SIZE},
+		},
+		{
+			name:          "several pages",
+			items:         items(2*DEFAULT_PAGE_SIZE + 50),
+			wantPageSizes: []int{DEFAULT_PAGE_SIZE, DEFAULT_PAGE_SIZE, 50},
+		},
+		{
+			name:          "duplicate keys across a page boundary",
+			items:         duplicated,
+			wantPageSizes: []int{DEFAULT_PAGE_SIZE, 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, pageSizes := paginateAll(t, tt.items)
+
+			if !slices.Equal(pageSizes, tt.wantPageSizes) {
+				t.Errorf("page sizes = %v, want %v", pageSizes, tt.wantPageSizes)
+			}
+
+			sorted := slices.Clone(tt.items)
+			slices.SortStableFunc(sorted, func(a, b item) int {
+				return strings.Compare(a.key, b.key)
+			})
+
+			var wantValues []int
+			for _, i := range sorted {
+				wantValues = append(wantValues, i.value)
+			}
+
+			if !slices.Equal(values, wantValues) {
+				t.Errorf("values = %v, want %v", values, wantValues)
+			}
+		})
+	}
+}
+
+func TestPaginateCursorSurvivesRemovedItems(t *testing.T) {
+	all := items(DEFAULT_PAGE_SIZE + 10)
+
+	_, cursor, err := paginate(slices.Clone(all), itemKey, "")
+	if err != nil {
+		t.Fatalf("paginate() error = %v", err)
+	}
+
+	// Dropping the last item served, as when its child goes away, must not
+	// make the next page skip or repeat any of the remaining items.
+	remaining := slices.DeleteFunc(slices.Clone(all), func(i item) bool {
+		return i.key == fmt.Sprintf("key%03d", DEFAULT_PAGE_SIZE-1)
+	})
+
+	page, nextCursor, err := paginate(remaining, itemKey, cursor)
+	if err != nil {
+		t.Fatalf("paginate(%q) error = %v", cursor, err)
+	}
+
+	if len(page) != 10 || page[0].value != DEFAULT_PAGE_SIZE || nextCursor != "" {
+		t.Errorf("next page = %d items starting at %v with cursor %q, want 10 items starting at %d and no cursor", len(page), page[0].value, nextCursor, DEFAULT_PAGE_SIZE)
+	}
+}
+
+func TestPaginateInvalidCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{
+			name:   "not base64",
+			cursor: "not a cursor!",
+		},
+		{
+			name:   "not JSON",
+			cursor: base64.RawURLEncoding.EncodeToString([]byte("after")),
+		},
+		{
+			name:   "no key",
+			cursor: encodeCursor(listCursor{Skip: 1}),
+		},
+		{
+			name:   "no skip",
+			cursor: encodeCursor(listCursor{After: "key001"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := paginate(items(3), itemKey, tt.cursor)
+
+			var jsonrpcErr *jsonrpc2.Error
+			if !errors.As(err, &jsonrpcErr) || jsonrpcErr.Code != jsonrpc2.CodeInvalidParams {
+				t.Errorf("paginate(%q) error = %v, want an invalid params error", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestCachedChildList(t *testing.T) {
+	tests := []struct {
+		name        string
+		listChanged bool
+		cursor      string
+		cached      bool
+		wantFetch   bool
+		wantCached  bool
+	}{
+		{
+			name:       "first page fetches and caches",
+			wantFetch:  true,
+			wantCached: true,
+		},
+		{
+			name:       "first page ignores the cache without list changes",
+			cached:     true,
+			wantFetch:  true,
+			wantCached: true,
+		},
+		{
+			name:        "first page uses the cache with list changes",
+			listChanged: true,
+			cached:      true,
+			wantCached:  true,
+		},
+		{
+			name:       "following pages use the cache",
+			cursor:     "cursor",
+			cached:     true,
+			wantCached: true,
+		},
+		{
+			name:       "following pages fetch when nothing is cached",
+			cursor:     "cursor",
+			wantFetch:  true,
+			wantCached: true,
+		},
+	}
+
+	const method = "prompts/list"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			child := &childServer{}
+			if tt.cached {
+				child.cacheList(method, child.listsGeneration(), []string{"cached"})
+			}
+
+			fetched := false
+			got, err := cachedChildList(context.Background(), child, method, tt.listChanged, tt.cursor, func(context.Context) ([]string, error) {
+				fetched = true
+				return []string{"fetched"}, nil
+			})
+			if err != nil {
+				t.Fatalf("cachedChildList() error = %v", err)
+			}
+
+			if fetched != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v", fetched, tt.wantFetch)
+			}
+
+			want := []string{"cached"}
+			if tt.wantFetch {
+				want = []string{"fetched"}
+			}
+
+			if !slices.Equal(got, want) {
+				t.Errorf("cachedChildList() = %v, want %v", got, want)
+			}
+
+			_, cached := child.cachedList(method)
+			if cached != tt.wantCached {
+				t.Errorf("cached = %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestCachedChildListChangedWhileFetching(t *testing.T) {
+	const method = "resources/list"
+
+	child := &childServer{}
+
+	_, err := cachedChildList(context.Background(), child, method, true, "", func(context.Context) ([]string, error) {
+		child.invalidateLists(method)
+		return []string{"stale"}, nil
+	})
+	if err != nil {
+		t.Fatalf("cachedChildList() error = %v", err)
+	}
+
+	if items, ok := child.cachedList(method); ok {
+		t.Errorf("cached %v fetched before the list changed", items)
+	}
+}
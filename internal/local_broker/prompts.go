@@ -0,0 +1,101 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func (lb *localBroker) handlePromptsListRequest(ctx context.Context, _ *session, req *mcp.PromptsListRequest) (*mcp.PromptsListResult, error) {
+	if _, err := decodeCursor(req.Cursor); err != nil {
+		return nil, err
+	}
+
+	prompts := fanOut(ctx, lb, func(ctx context.Context, child *childServer, conn serverrunner.ServerConn) ([]mcp.Prompt, error) {
+		capability := conn.InitializeResult().Capabilities.Prompts
+		if capability == nil {
+			return nil, nil
+		}
+
+		listChanged := capability.ListChanged != nil && *capability.ListChanged
+
+		return cachedChildList(ctx, child, "prompts/list", listChanged, req.Cursor, func(ctx context.Context) ([]mcp.Prompt, error) {
+			prompts, err := listChildPages(ctx, func(ctx context.Context, cursor string) ([]mcp.Prompt, string, error) {
+				var result mcp.PromptsListResult
+				if err := conn.Call(ctx, "prompts/list", &mcp.PromptsListRequest{Cursor: cursor}, &result); err != nil {
+					return nil, "", err
+				}
+
+				return result.Prompts, result.NextCursor, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error listing prompts: %w", err)
+			}
+
+			for i := range prompts {
+				prompts[i].Name = namespacedName(child.prefix, prompts[i].Name)
+			}
+
+			return prompts, nil
+		})
+	})
+
+	page, nextCursor, err := paginate(prompts, func(prompt mcp.Prompt) string { return prompt.Name }, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.PromptsListResult{
+		Prompts:    page,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+func (lb *localBroker) handlePromptsGetRequest(ctx context.Context, sess *session, req *mcp.PromptsGetRequest) (*mcp.PromptsGetResult, error) {
+	errPromptNotFound := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: fmt.Sprintf("prompt %q not found", req.Name),
+	}
+
+	prefix, promptName, ok := splitNamespacedName(req.Name)
+	if !ok {
+		return nil, errPromptNotFound
+	}
+
+	child, ok := lb.getChildByPrefix(prefix)
+	if !ok {
+		return nil, errPromptNotFound
+	}
+
+	conn, err := child.readyConn()
+	if err != nil {
+		return nil, err
+	}
+
+	if conn.InitializeResult().Capabilities.Prompts == nil {
+		return nil, errPromptNotFound
+	}
+
+	meta, release := lb.progress.forward(sess, req.Meta)
+	defer release()
+
+	var result mcp.PromptsGetResult
+	if err := lb.callChild(ctx, sess, child, conn, "prompts/get", &mcp.PromptsGetRequest{
+		Meta:      meta,
+		Name:      promptName,
+		Arguments: req.Arguments,
+	}, &result); err != nil {
+		return nil, childCallError(prefix, err)
+	}
+
+	for _, message := range result.Messages {
+		namespaceContentURI(child.prefix, message.Content)
+	}
+
+	adaptPromptsGetResult(&result, sess.getClient().protocolVersion)
+
+	return &result, nil
+}
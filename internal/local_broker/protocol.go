@@ -0,0 +1,80 @@
+package localbroker
+
+import (
+	"encoding/json"
+	"fmt"
+	"mcp/internal/mcp"
+)
+
+// clientState captures what the client declared when initializing.
+type clientState struct {
+	protocolVersion string
+	capabilities    mcp.ClientCapabilities
+	info            mcp.ImplementationInfo
+}
+
+// adaptToolsCallResult rewrites a tool call result, possibly produced by a
+// child speaking a newer protocol revision, into a shape that a client
+// speaking the given revision understands.
+//
+// Results are only ever down-converted: a child speaking an older revision
+// doesn't declare output schemas, so newer clients expect no structured
+// content from its tools and there is nothing to derive it from. What older
+// children declare about their tools is upgraded by adaptChildTools instead.
+func adaptToolsCallResult(result *mcp.ToolsCallResult, version string) {
+	for i := range result.Content {
+		result.Content[i] = adaptContent(result.Content[i], version)
+	}
+
+	if result.StructuredContent != nil && !mcp.ProtocolVersionAtLeast(version, mcp.PROTOCOL_VERSION_2025_06_18) {
+		// Older clients only see the unstructured content, which should carry
+		// the serialized structured content when nothing else is provided.
+		if len(result.Content) == 0 {
+			if b, err := json.Marshal(result.StructuredContent); err == nil {
+				result.Content = append(result.Content, mcp.NewTextContent(string(b)))
+			}
+		}
+
+		result.StructuredContent = nil
+	}
+}
+
+// adaptChildTools upgrades the tools advertised by a child speaking the given
+// protocol revision to what newer clients expect. Revisions before 2025-06-18
+// could only title a tool through its annotations.
+func adaptChildTools(tools []mcp.ToolDefinition, version string) {
+	if mcp.ProtocolVersionAtLeast(version, mcp.PROTOCOL_VERSION_2025_06_18) {
+		return
+	}
+
+	for i := range tools {
+		if tools[i].Title == "" && tools[i].Annotations != nil {
+			tools[i].Title = tools[i].Annotations.Title
+		}
+	}
+}
+
+// adaptPromptsGetResult is the prompts/get counterpart of
+// adaptToolsCallResult.
+func adaptPromptsGetResult(result *mcp.PromptsGetResult, version string) {
+	for i := range result.Messages {
+		result.Messages[i].Content = adaptContent(result.Messages[i].Content, version)
+	}
+}
+
+// adaptContent replaces content types introduced after the given protocol
+// revision with a textual description of them.
+func adaptContent(content mcp.Content, version string) mcp.Content {
+	switch {
+	case content.Audio != nil:
+		if !mcp.ProtocolVersionAtLeast(version, mcp.PROTOCOL_VERSION_2025_03_26) {
+			return mcp.NewTextContent(fmt.Sprintf("[audio content of type %s omitted]", content.Audio.MimeType))
+		}
+	case content.ResourceLink != nil:
+		if !mcp.ProtocolVersionAtLeast(version, mcp.PROTOCOL_VERSION_2025_06_18) {
+			return mcp.NewTextContent(fmt.Sprintf("Resource %s: %s", content.ResourceLink.Name, content.ResourceLink.URI))
+		}
+	}
+
+	return content
+}
@@ -0,0 +1,195 @@
+package localbroker
+
+import (
+	"context"
+	"mcp/internal/mcp"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	NOTIFICATION_CANCELLED = "notifications/cancelled"
+	NOTIFICATION_PROGRESS  = "notifications/progress"
+)
+
+// ORIGIN_CLIENT identifies requests issued by a session's client, as opposed
+// to those issued by children which are identified by their integration id.
+const ORIGIN_CLIENT = "client"
+
+// inflightRequests tracks the requests being handled by the broker so that
+// they can be cancelled by their sender. Requests are keyed by their origin,
+// either the client or a child, and their JSON-RPC id. Each session tracks
+// the requests of its client while the broker tracks those of children.
+type inflightRequests struct {
+	mu      sync.Mutex
+	cancels map[inflightRequestKey]context.CancelFunc
+}
+
+type inflightRequestKey struct {
+	origin string
+	id     jsonrpc2.ID
+}
+
+func newInflightRequests() *inflightRequests {
+	return &inflightRequests{
+		cancels: make(map[inflightRequestKey]context.CancelFunc),
+	}
+}
+
+// track derives a context for handling the given request that is cancelled
+// by cancel(origin, id). The returned function must be called once the
+// request has been handled.
+func (r *inflightRequests) track(ctx context.Context, origin string, id jsonrpc2.ID) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := inflightRequestKey{origin: origin, id: id}
+
+	r.mu.Lock()
+	r.cancels[key] = cancel
+	r.mu.Unlock()
+
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.cancels, key)
+		r.mu.Unlock()
+
+		cancel()
+	}
+}
+
+// cancel cancels the context of an in-flight request and reports whether the
+// request was found.
+func (r *inflightRequests) cancel(origin string, id jsonrpc2.ID) bool {
+	r.mu.Lock()
+	cancel, ok := r.cancels[inflightRequestKey{origin: origin, id: id}]
+	r.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+
+	return ok
+}
+
+// progressTokens maps the progress tokens attached to requests forwarded to
+// children back to the sessions and tokens chosen by their clients. The
+// broker substitutes its own tokens so that tokens chosen independently by
+// different senders can't collide.
+type progressTokens struct {
+	mu      sync.Mutex
+	byToken map[string]progressTarget
+}
+
+// progressTarget is where the progress reported for one of the broker's
+// tokens is relayed.
+type progressTarget struct {
+	session *session
+	token   any
+}
+
+func newProgressTokens() *progressTokens {
+	return &progressTokens{
+		byToken: make(map[string]progressTarget),
+	}
+}
+
+// forward returns a copy of meta in which the client's progress token, if
+// any, is replaced by one of the broker's. The returned function must be
+// called once the request has completed.
+func (p *progressTokens) forward(sess *session, meta mcp.Meta) (mcp.Meta, func()) {
+	clientToken, ok := meta.ProgressToken()
+	if !ok {
+		return meta, func() {}
+	}
+
+	token := uuid.NewString()
+
+	p.mu.Lock()
+	p.byToken[token] = progressTarget{session: sess, token: clientToken}
+	p.mu.Unlock()
+
+	forwarded := make(mcp.Meta, len(meta))
+	for k, v := range meta {
+		forwarded[k] = v
+	}
+	forwarded["progressToken"] = token
+
+	return forwarded, func() {
+		p.mu.Lock()
+		delete(p.byToken, token)
+		p.mu.Unlock()
+	}
+}
+
+// target returns the session and client progress token for one of the
+// broker's tokens.
+func (p *progressTokens) target(token any) (progressTarget, bool) {
+	s, ok := token.(string)
+	if !ok {
+		return progressTarget{}, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	target, ok := p.byToken[s]
+	return target, ok
+}
+
+// rpcConn is the part of a JSON-RPC connection used to issue requests, common
+// to the client's connection and the children's.
+type rpcConn interface {
+	Call(ctx context.Context, method string, params, result interface{}, opts ...jsonrpc2.CallOption) error
+	Notify(ctx context.Context, method string, params interface{}, opts ...jsonrpc2.CallOption) error
+}
+
+// callCancellable issues a request over conn and, if ctx is cancelled before
+// the response arrives, lets the receiver know that the request was cancelled.
+func (lb *localBroker) callCancellable(ctx context.Context, conn rpcConn, method string, params, result interface{}) error {
+	id := jsonrpc2.ID{Str: uuid.NewString(), IsString: true}
+
+	err := conn.Call(ctx, method, params, result, jsonrpc2.PickID(id))
+	if err != nil && ctx.Err() != nil {
+		if err := conn.Notify(context.WithoutCancel(ctx), NOTIFICATION_CANCELLED, &mcp.CancelledNotification{
+			RequestId: id,
+			Reason:    context.Cause(ctx).Error(),
+		}); err != nil {
+			lb.logger.Error("error sending cancellation", "method", method, "err", err)
+		}
+	}
+
+	return err
+}
+
+// callChild issues a request to a child on behalf of a session, so that the
+// requests the child makes while handling it are routed to that session.
+func (lb *localBroker) callChild(ctx context.Context, sess *session, child *childServer, conn rpcConn, method string, params, result interface{}) error {
+	release := child.trackCaller(sess)
+	defer release()
+
+	return lb.callCancellable(ctx, conn, method, params, result)
+}
+
+// handleCancelledNotification cancels a request previously issued by origin.
+func (lb *localBroker) handleCancelledNotification(inflight *inflightRequests, origin string, n *mcp.CancelledNotification) {
+	if inflight.cancel(origin, n.RequestId) {
+		lb.logger.Debug("request cancelled", "origin", origin, "id", n.RequestId.String(), "reason", n.Reason)
+	}
+}
+
+// handleChildProgressNotification relays progress reported by a child to the
+// client waiting on the request it concerns, if any.
+func (lb *localBroker) handleChildProgressNotification(ctx context.Context, _ *childServer, n *mcp.ProgressNotification) error {
+	target, ok := lb.progress.target(n.ProgressToken)
+	if !ok {
+		return nil
+	}
+
+	return target.session.conn.Notify(ctx, NOTIFICATION_PROGRESS, &mcp.ProgressNotification{
+		ProgressToken: target.token,
+		Progress:      n.Progress,
+		Total:         n.Total,
+		Message:       n.Message,
+	})
+}
@@ -0,0 +1,326 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"slices"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// resourceSubscriptions tracks the resources a client has subscribed to,
+// keyed by the integration id of the owning child and the child's own URI.
+type resourceSubscriptions struct {
+	mu      sync.Mutex
+	byChild map[string]map[string]struct{}
+}
+
+func newResourceSubscriptions() *resourceSubscriptions {
+	return &resourceSubscriptions{
+		byChild: make(map[string]map[string]struct{}),
+	}
+}
+
+func (s *resourceSubscriptions) add(integrationId, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uris, ok := s.byChild[integrationId]
+	if !ok {
+		uris = make(map[string]struct{})
+		s.byChild[integrationId] = uris
+	}
+
+	uris[uri] = struct{}{}
+}
+
+// remove drops a subscription and reports whether it existed.
+func (s *resourceSubscriptions) remove(integrationId, uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uris, ok := s.byChild[integrationId]
+	if !ok {
+		return false
+	}
+
+	if _, ok := uris[uri]; !ok {
+		return false
+	}
+
+	delete(uris, uri)
+	if len(uris) == 0 {
+		delete(s.byChild, integrationId)
+	}
+
+	return true
+}
+
+func (s *resourceSubscriptions) has(integrationId, uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.byChild[integrationId][uri]
+	return ok
+}
+
+// dropChild forgets every subscription to resources of the given child.
+func (s *resourceSubscriptions) dropChild(integrationId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.byChild, integrationId)
+}
+
+// all returns the subscribed URIs keyed by integration id.
+func (s *resourceSubscriptions) all() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := make(map[string][]string, len(s.byChild))
+	for integrationId, uris := range s.byChild {
+		for uri := range uris {
+			all[integrationId] = append(all[integrationId], uri)
+		}
+	}
+
+	return all
+}
+
+// subscribedSessions returns the sessions subscribed to a child's resource.
+func (lb *localBroker) subscribedSessions(integrationId, uri string) []*session {
+	return slices.DeleteFunc(lb.listSessions(), func(sess *session) bool {
+		return !sess.subscriptions.has(integrationId, uri)
+	})
+}
+
+func (lb *localBroker) handleResourcesListRequest(ctx context.Context, _ *session, req *mcp.ResourcesListRequest) (*mcp.ResourcesListResult, error) {
+	if _, err := decodeCursor(req.Cursor); err != nil {
+		return nil, err
+	}
+
+	resources := fanOut(ctx, lb, func(ctx context.Context, child *childServer, conn serverrunner.ServerConn) ([]mcp.Resource, error) {
+		capability := conn.InitializeResult().Capabilities.Resources
+		if capability == nil {
+			return nil, nil
+		}
+
+		listChanged := capability.ListChanged != nil && *capability.ListChanged
+
+		return cachedChildList(ctx, child, "resources/list", listChanged, req.Cursor, func(ctx context.Context) ([]mcp.Resource, error) {
+			resources, err := listChildPages(ctx, func(ctx context.Context, cursor string) ([]mcp.Resource, string, error) {
+				var result mcp.ResourcesListResult
+				if err := conn.Call(ctx, "resources/list", &mcp.ResourcesListRequest{Cursor: cursor}, &result); err != nil {
+					return nil, "", err
+				}
+
+				return result.Resources, result.NextCursor, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error listing resources: %w", err)
+			}
+
+			for i := range resources {
+				resources[i].URI = namespacedURI(child.prefix, resources[i].URI)
+			}
+
+			return resources, nil
+		})
+	})
+
+	page, nextCursor, err := paginate(resources, func(resource mcp.Resource) string { return resource.URI }, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.ResourcesListResult{
+		Resources:  page,
+		NextCursor: nextCursor,
+	}, nil
+}
+
+func (lb *localBroker) handleResourceTemplatesListRequest(ctx context.Context, _ *session, req *mcp.ResourceTemplatesListRequest) (*mcp.ResourceTemplatesListResult, error) {
+	if _, err := decodeCursor(req.Cursor); err != nil {
+		return nil, err
+	}
+
+	templates := fanOut(ctx, lb, func(ctx context.Context, child *childServer, conn serverrunner.ServerConn) ([]mcp.ResourceTemplate, error) {
+		capability := conn.InitializeResult().Capabilities.Resources
+		if capability == nil {
+			return nil, nil
+		}
+
+		listChanged := capability.ListChanged != nil && *capability.ListChanged
+
+		return cachedChildList(ctx, child, "resources/templates/list", listChanged, req.Cursor, func(ctx context.Context) ([]mcp.ResourceTemplate, error) {
+			templates, err := listChildPages(ctx, func(ctx context.Context, cursor string) ([]mcp.ResourceTemplate, string, error) {
+				var result mcp.ResourceTemplatesListResult
+				if err := conn.Call(ctx, "resources/templates/list", &mcp.ResourceTemplatesListRequest{Cursor: cursor}, &result); err != nil {
+					return nil, "", err
+				}
+
+				return result.ResourceTemplates, result.NextCursor, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error listing resource templates: %w", err)
+			}
+
+			for i := range templates {
+				templates[i].URITemplate = namespacedURI(child.prefix, templates[i].URITemplate)
+			}
+
+			return templates, nil
+		})
+	})
+
+	page, nextCursor, err := paginate(templates, func(template mcp.ResourceTemplate) string { return template.URITemplate }, req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.ResourceTemplatesListResult{
+		ResourceTemplates: page,
+		NextCursor:        nextCursor,
+	}, nil
+}
+
+func (lb *localBroker) handleResourcesReadRequest(ctx context.Context, sess *session, req *mcp.ResourcesReadRequest) (*mcp.ResourcesReadResult, error) {
+	child, conn, uri, err := lb.resolveResourceURI(req.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, release := lb.progress.forward(sess, req.Meta)
+	defer release()
+
+	var result mcp.ResourcesReadResult
+	if err := lb.callChild(ctx, sess, child, conn, "resources/read", &mcp.ResourcesReadRequest{
+		Meta: meta,
+		URI:  uri,
+	}, &result); err != nil {
+		return nil, childCallError(child.prefix, err)
+	}
+
+	for i := range result.Contents {
+		result.Contents[i].URI = namespacedURI(child.prefix, result.Contents[i].URI)
+	}
+
+	return &result, nil
+}
+
+// handleResourcesSubscribeRequest subscribes the session to a resource. The
+// child is only asked to subscribe when no other session already is.
+func (lb *localBroker) handleResourcesSubscribeRequest(ctx context.Context, sess *session, req *mcp.ResourcesSubscribeRequest) (*mcp.EmptyResult, error) {
+	child, conn, uri, err := lb.resolveResourceURI(req.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if capability := conn.InitializeResult().Capabilities.Resources; capability.Subscribe == nil || !*capability.Subscribe {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("server %q does not support resource subscriptions", child.prefix),
+		}
+	}
+
+	if len(lb.subscribedSessions(child.integrationId, uri)) == 0 {
+		if err := conn.Call(ctx, "resources/subscribe", &mcp.ResourcesSubscribeRequest{
+			URI: uri,
+		}, nil); err != nil {
+			return nil, childCallError(child.prefix, err)
+		}
+	}
+
+	sess.subscriptions.add(child.integrationId, uri)
+
+	return &mcp.EmptyResult{}, nil
+}
+
+func (lb *localBroker) handleResourcesUnsubscribeRequest(ctx context.Context, sess *session, req *mcp.ResourcesUnsubscribeRequest) (*mcp.EmptyResult, error) {
+	prefix, uri, ok := splitNamespacedURI(req.URI)
+	if !ok {
+		return &mcp.EmptyResult{}, nil
+	}
+
+	child, ok := lb.getChildByPrefix(prefix)
+	if !ok || !sess.subscriptions.remove(child.integrationId, uri) {
+		return &mcp.EmptyResult{}, nil
+	}
+
+	if err := lb.unsubscribeChild(ctx, child, uri); err != nil {
+		return nil, err
+	}
+
+	return &mcp.EmptyResult{}, nil
+}
+
+// unsubscribeChild asks a child to stop sending updates about a resource,
+// unless some session is still subscribed to it.
+func (lb *localBroker) unsubscribeChild(ctx context.Context, child *childServer, uri string) error {
+	if len(lb.subscribedSessions(child.integrationId, uri)) > 0 {
+		return nil
+	}
+
+	conn, err := child.readyConn()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Call(ctx, "resources/unsubscribe", &mcp.ResourcesUnsubscribeRequest{
+		URI: uri,
+	}, nil); err != nil {
+		return childCallError(child.prefix, err)
+	}
+
+	return nil
+}
+
+// handleChildResourceUpdatedNotification relays a child's resource update to
+// the clients subscribed to that resource.
+func (lb *localBroker) handleChildResourceUpdatedNotification(ctx context.Context, child *childServer, n *mcp.ResourceUpdatedNotification) error {
+	var result error
+
+	for _, sess := range lb.subscribedSessions(child.integrationId, n.URI) {
+		if err := sess.conn.Notify(ctx, "notifications/resources/updated", &mcp.ResourceUpdatedNotification{
+			URI: namespacedURI(child.prefix, n.URI),
+		}); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	return result
+}
+
+// resolveResourceURI finds the ready child owning a namespaced resource URI
+// and returns it along with the child's own URI for the resource.
+func (lb *localBroker) resolveResourceURI(namespaced string) (*childServer, serverrunner.ServerConn, string, error) {
+	errResourceNotFound := &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeInvalidParams,
+		Message: fmt.Sprintf("resource %q not found", namespaced),
+	}
+
+	prefix, uri, ok := splitNamespacedURI(namespaced)
+	if !ok {
+		return nil, nil, "", errResourceNotFound
+	}
+
+	child, ok := lb.getChildByPrefix(prefix)
+	if !ok {
+		return nil, nil, "", errResourceNotFound
+	}
+
+	conn, err := child.readyConn()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	if conn.InitializeResult().Capabilities.Resources == nil {
+		return nil, nil, "", errResourceNotFound
+	}
+
+	return child, conn, uri, nil
+}
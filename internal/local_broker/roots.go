@@ -0,0 +1,220 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"net/url"
+	"path"
+	"slices"
+	"time"
+)
+
+// CONTAINER_ROOTS_DIR is the directory under which the clients' roots are
+// mounted into child servers when LocalBrokerOptions.MountRoots is set, each
+// client's in a directory of its own.
+const CONTAINER_ROOTS_DIR = "/roots"
+
+// REMOUNT_DRAIN_TIMEOUT_SECONDS bounds how long a child whose mounts changed
+// is left to complete the requests in flight before being restarted.
+const REMOUNT_DRAIN_TIMEOUT_SECONDS = 60
+
+const NOTIFICATION_ROOTS_LIST_CHANGED = "notifications/roots/list_changed"
+
+// allRoots returns the roots of every client, without duplicates.
+func (lb *localBroker) allRoots() []mcp.Root {
+	var roots []mcp.Root
+	seen := make(map[string]bool)
+
+	for _, sess := range lb.listSessions() {
+		for _, root := range sess.getRoots() {
+			if !seen[root.URI] {
+				seen[root.URI] = true
+				roots = append(roots, root)
+			}
+		}
+	}
+
+	return roots
+}
+
+// refreshRoots fetches the roots of a session's client and propagates any
+// change to the children.
+func (lb *localBroker) refreshRoots(ctx context.Context, sess *session) error {
+	if sess.getClient().capabilities.Roots == nil {
+		return nil
+	}
+
+	var result mcp.RootsListResult
+	if err := sess.conn.Call(ctx, "roots/list", &mcp.RootsListRequest{}, &result); err != nil {
+		return fmt.Errorf("error listing client roots: %w", err)
+	}
+
+	mounts := lb.childMounts()
+
+	if !sess.setRoots(result.Roots) {
+		return nil
+	}
+
+	sess.logger.Info("client roots changed", "count", len(result.Roots))
+
+	remount := lb.options.MountRoots && !slices.Equal(mounts, lb.childMounts())
+
+	if remount {
+		lb.restartMountingChildren()
+	}
+
+	for _, child := range lb.listChildren() {
+		if remount && !child.remote {
+			continue
+		}
+
+		conn, ok := child.getConn()
+		if !ok {
+			continue
+		}
+
+		if err := conn.Notify(ctx, NOTIFICATION_ROOTS_LIST_CHANGED, &mcp.ListChangedNotification{}); err != nil {
+			lb.logger.Error("error notifying child of roots change", "prefix", child.prefix, "err", err)
+		}
+	}
+
+	return nil
+}
+
+func (lb *localBroker) handleRootsListChangedNotification(ctx context.Context, sess *session, _ *mcp.ListChangedNotification) error {
+	return lb.refreshRoots(ctx, sess)
+}
+
+// handleChildRootsListRequest serves the roots of the client the child is
+// working for, as seen from within the child's container when roots are
+// mounted. Remote children see them as they are on the host. A child asking
+// outside of any client's request, such as when it starts, is served the
+// roots of every client.
+func (lb *localBroker) handleChildRootsListRequest(_ context.Context, child *childServer, req *mcp.RootsListRequest) (*mcp.RootsListResult, error) {
+	sessions := lb.listSessions()
+	if sess, ok := lb.sessionForChild(child, req.Meta, func(*session) bool { return true }); ok {
+		sessions = []*session{sess}
+	}
+
+	roots := []mcp.Root{}
+	for _, sess := range sessions {
+		if lb.options.MountRoots && !child.remote {
+			_, rewritten := containerRoots(sessionRootsDir(sess), sess.getRoots())
+			roots = append(roots, rewritten...)
+			continue
+		}
+
+		for _, root := range sess.getRoots() {
+			if !slices.ContainsFunc(roots, func(other mcp.Root) bool { return other.URI == root.URI }) {
+				roots = append(roots, root)
+			}
+		}
+	}
+
+	return &mcp.RootsListResult{
+		Roots: roots,
+	}, nil
+}
+
+// restartMountingChildren restarts the children that mount the clients'
+// roots. Mounts are fixed when a container is created so children need to be
+// restarted to see new roots.
+func (lb *localBroker) restartMountingChildren() {
+	for _, child := range lb.listChildren() {
+		if !child.remote {
+			go lb.restartChildWhenIdle(child)
+		}
+	}
+}
+
+// restartChildWhenIdle restarts a child once it has no request from a client
+// in flight, so that clients aren't interrupted by another's roots changing,
+// or after REMOUNT_DRAIN_TIMEOUT_SECONDS.
+func (lb *localBroker) restartChildWhenIdle(child *childServer) {
+	timeout := time.NewTimer(time.Duration(REMOUNT_DRAIN_TIMEOUT_SECONDS) * time.Second)
+	defer timeout.Stop()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for len(child.callerSessions()) > 0 {
+		select {
+		case <-lb.runCtx.Done():
+			return
+		case <-child.done:
+			return
+		case <-timeout.C:
+			lb.logger.Warn("restarting integration with requests in flight", "id", child.integrationId, "reason", "roots changed")
+			lb.restartChild(child)
+			return
+		case <-ticker.C:
+		}
+	}
+
+	lb.restartChild(child)
+}
+
+// childMounts returns the mounts needed to expose the clients' roots to a
+// child, if roots are to be mounted at all.
+func (lb *localBroker) childMounts() []serverrunner.Mount {
+	if !lb.options.MountRoots {
+		return nil
+	}
+
+	var mounts []serverrunner.Mount
+	for _, sess := range lb.listSessions() {
+		sessionMounts, _ := containerRoots(sessionRootsDir(sess), sess.getRoots())
+		mounts = append(mounts, sessionMounts...)
+	}
+
+	return mounts
+}
+
+// sessionRootsDir is the directory under which a session's roots are mounted.
+func sessionRootsDir(sess *session) string {
+	id := sess.id
+	if len(id) > 8 {
+		id = id[:8]
+	}
+
+	return path.Join(CONTAINER_ROOTS_DIR, id)
+}
+
+// containerRoots maps a client's file:// roots onto directories under dir. It
+// returns the mounts to create and the roots with their URIs rewritten to the
+// in-container paths. Roots that aren't local files are passed through
+// unchanged.
+func containerRoots(dir string, roots []mcp.Root) ([]serverrunner.Mount, []mcp.Root) {
+	var mounts []serverrunner.Mount
+	rewritten := make([]mcp.Root, 0, len(roots))
+	used := make(map[string]bool)
+
+	for _, root := range roots {
+		u, err := url.Parse(root.URI)
+		if err != nil || u.Scheme != "file" || u.Path == "" {
+			rewritten = append(rewritten, root)
+			continue
+		}
+
+		name := namespacePrefix(path.Base(u.Path))
+		containerPath := path.Join(dir, name)
+		for i := 2; used[containerPath]; i++ {
+			containerPath = path.Join(dir, fmt.Sprintf("%s_%d", name, i))
+		}
+		used[containerPath] = true
+
+		mounts = append(mounts, serverrunner.Mount{
+			HostPath:   u.Path,
+			ServerPath: containerPath,
+		})
+
+		rewritten = append(rewritten, mcp.Root{
+			URI:  (&url.URL{Scheme: "file", Path: containerPath}).String(),
+			Name: root.Name,
+		})
+	}
+
+	return mounts, rewritten
+}
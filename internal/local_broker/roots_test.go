@@ -0,0 +1,86 @@
+package localbroker
+
+import (
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestContainerRoots(t *testing.T) {
+	tests := []struct {
+		name       string
+		roots      []mcp.Root
+		wantMounts []serverrunner.Mount
+		wantRoots  []mcp.Root
+	}{
+		{
+			name:      "no roots",
+			wantRoots: []mcp.Root{},
+		},
+		{
+			name: "file roots",
+			roots: []mcp.Root{
+				{URI: "file:///home/user/project", Name: "Project"},
+				{URI: "file:///home/user/My%20Notes"},
+			},
+			wantMounts: []serverrunner.Mount{
+				{HostPath: "/home/user/project", ServerPath: "/roots/abc/project"},
+				{HostPath: "/home/user/My Notes", ServerPath: "/roots/abc/my_notes"},
+			},
+			wantRoots: []mcp.Root{
+				{URI: "file:///roots/abc/project", Name: "Project"},
+				{URI: "file:///roots/abc/my_notes"},
+			},
+		},
+		{
+			name: "same base names",
+			roots: []mcp.Root{
+				{URI: "file:///a/src"},
+				{URI: "file:///b/src"},
+				{URI: "file:///c/src"},
+			},
+			wantMounts: []serverrunner.Mount{
+				{HostPath: "/a/src", ServerPath: "/roots/abc/src"},
+				{HostPath: "/b/src", ServerPath: "/roots/abc/src_2"},
+				{HostPath: "/c/src", ServerPath: "/roots/abc/src_3"},
+			},
+			wantRoots: []mcp.Root{
+				{URI: "file:///roots/abc/src"},
+				{URI: "file:///roots/abc/src_2"},
+				{URI: "file:///roots/abc/src_3"},
+			},
+		},
+		{
+			name: "other roots are passed through",
+			roots: []mcp.Root{
+				{URI: "https://example.com/repo", Name: "Remote"},
+				{URI: "file:///home/user/project"},
+				{URI: "file://"},
+			},
+			wantMounts: []serverrunner.Mount{
+				{HostPath: "/home/user/project", ServerPath: "/roots/abc/project"},
+			},
+			wantRoots: []mcp.Root{
+				{URI: "https://example.com/repo", Name: "Remote"},
+				{URI: "file:///roots/abc/project"},
+				{URI: "file://"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mounts, roots := containerRoots("/roots/abc", tt.roots)
+
+			if !slices.Equal(mounts, tt.wantMounts) {
+				t.Errorf("mounts = %v, want %v", mounts, tt.wantMounts)
+			}
+
+			if !reflect.DeepEqual(roots, tt.wantRoots) {
+				t.Errorf("roots = %v, want %v", roots, tt.wantRoots)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package localbroker
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/audit"
+	"mcp/internal/mcp"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// handleChildSamplingRequest forwards a child's sampling/createMessage
+// request to the client of the session the child is working for, among those
+// supporting sampling.
+func (lb *localBroker) handleChildSamplingRequest(ctx context.Context, child *childServer, id jsonrpc2.ID, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	sess, ok := lb.sessionForChild(child, req.Meta, func(sess *session) bool {
+		return sess.getClient().capabilities.Sampling != nil
+	})
+	if !ok {
+		lb.logger.Warn("refusing sampling request from child", "prefix", child.prefix, "reason", "no request from a client supporting sampling is in flight")
+
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: "no client supporting sampling has a request to this server in flight",
+		}
+	}
+
+	lb.recordAudit(ctx, &audit.Record{
+		SessionId: sess.id,
+		Type:      audit.RecordTypeRequest,
+		Origin:    child.prefix,
+		RequestId: id.String(),
+		Operation: map[string]any{
+			"method": "sampling/createMessage",
+			"params": req,
+		},
+	})
+
+	result, err := lb.forwardSamplingRequest(ctx, sess, child, req)
+
+	response := map[string]any{"result": result}
+	if err != nil {
+		response = map[string]any{"error": err}
+	}
+
+	lb.recordAudit(ctx, &audit.Record{
+		SessionId: sess.id,
+		Type:      audit.RecordTypeResponse,
+		Origin:    child.prefix,
+		RequestId: id.String(),
+		Operation: response,
+	})
+
+	return result, err
+}
+
+func (lb *localBroker) forwardSamplingRequest(ctx context.Context, sess *session, child *childServer, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	if sess.getClient().capabilities.Sampling == nil {
+		sess.logger.Warn("refusing sampling request from child", "prefix", child.prefix, "reason", "client does not support sampling")
+
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidRequest,
+			Message: "sampling is not supported by the connected client",
+		}
+	}
+
+	var result mcp.CreateMessageResult
+	if err := lb.callCancellable(ctx, sess.conn, "sampling/createMessage", req, &result); err != nil {
+		if rpcErr, ok := err.(*jsonrpc2.Error); ok {
+			return nil, rpcErr
+		}
+
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: fmt.Sprintf("error requesting sampling from client: %v", err),
+		}
+	}
+
+	return &result, nil
+}
+
+// recordAudit writes to the audit log, logging rather than failing the
+// operation being audited if that isn't possible.
+func (lb *localBroker) recordAudit(ctx context.Context, r *audit.Record) {
+	if err := lb.auditLog.Record(ctx, r); err != nil {
+		lb.logger.Error("error recording audit log", "type", r.Type, "origin", r.Origin, "err", err)
+	}
+}
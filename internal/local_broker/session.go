@@ -0,0 +1,204 @@
+package localbroker
+
+import (
+	"context"
+	"log/slog"
+	"mcp/internal/jsonrpc"
+	"mcp/internal/mcp"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// session is a client connected to the broker. Children are shared by every
+// session while what a client declared and asked for is tracked here.
+type session struct {
+	id     string
+	logger *slog.Logger
+	conn   *jsonrpc2.Conn
+
+	initialized atomic.Bool
+	// completions is set when completions were offered to the client.
+	completions atomic.Bool
+
+	subscriptions *resourceSubscriptions
+	inflight      *inflightRequests
+
+	client   clientState
+	clientMu sync.RWMutex
+
+	roots   []mcp.Root
+	rootsMu sync.RWMutex
+
+	loggingLevel   mcp.LoggingLevel
+	loggingLevelMu sync.RWMutex
+}
+
+func newSession(logger *slog.Logger) *session {
+	id := uuid.NewString()
+
+	return &session{
+		id:            id,
+		logger:        logger.With("session", id),
+		subscriptions: newResourceSubscriptions(),
+		inflight:      newInflightRequests(),
+	}
+}
+
+func (s *session) setClient(client clientState) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+
+	s.client = client
+}
+
+func (s *session) getClient() clientState {
+	s.clientMu.RLock()
+	defer s.clientMu.RUnlock()
+
+	return s.client
+}
+
+func (s *session) setRoots(roots []mcp.Root) (changed bool) {
+	s.rootsMu.Lock()
+	defer s.rootsMu.Unlock()
+
+	changed = !slices.EqualFunc(s.roots, roots, func(a, b mcp.Root) bool {
+		return a.URI == b.URI && a.Name == b.Name
+	})
+	s.roots = roots
+
+	return changed
+}
+
+func (s *session) getRoots() []mcp.Root {
+	s.rootsMu.RLock()
+	defer s.rootsMu.RUnlock()
+
+	return slices.Clone(s.roots)
+}
+
+// setLoggingLevel records the level requested by the client. The zero value
+// means the client never asked for one.
+func (s *session) setLoggingLevel(level mcp.LoggingLevel) {
+	s.loggingLevelMu.Lock()
+	defer s.loggingLevelMu.Unlock()
+
+	s.loggingLevel = level
+}
+
+func (s *session) getLoggingLevel() mcp.LoggingLevel {
+	s.loggingLevelMu.RLock()
+	defer s.loggingLevelMu.RUnlock()
+
+	return s.loggingLevel
+}
+
+// ServeSession serves a client connected over stream until it disconnects or
+// ctx is cancelled, in which case it returns ErrConnectionClosed or nil
+// respectively.
+func (lb *localBroker) ServeSession(ctx context.Context, stream jsonrpc2.ObjectStream) error {
+	sess := newSession(lb.logger)
+
+	handler := jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(func(ctx context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+		return lb.handleRequest(ctx, sess, req)
+	}).SuppressErrClosed())
+	sess.conn = jsonrpc2.NewConn(ctx, stream, handler, jsonrpc2.LogMessages(jsonrpc.NewJSONRPCLogger(sess.logger)))
+	defer sess.conn.Close()
+
+	lb.addSession(sess)
+	defer lb.removeSession(ctx, sess)
+
+	sess.logger.Info("session started")
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-sess.conn.DisconnectNotify():
+		return ErrConnectionClosed
+	}
+}
+
+func (lb *localBroker) addSession(sess *session) {
+	lb.sessionsMu.Lock()
+	defer lb.sessionsMu.Unlock()
+
+	lb.sessions = append(lb.sessions, sess)
+}
+
+// removeSession forgets a session that ended, dropping the subscriptions to
+// child resources that no other session holds and unmounting its roots.
+func (lb *localBroker) removeSession(ctx context.Context, sess *session) {
+	mounts := lb.childMounts()
+
+	lb.sessionsMu.Lock()
+	lb.sessions = slices.DeleteFunc(lb.sessions, func(other *session) bool {
+		return other == sess
+	})
+	lb.sessionsMu.Unlock()
+
+	sess.logger.Info("session ended")
+
+	if !slices.Equal(mounts, lb.childMounts()) {
+		lb.restartMountingChildren()
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	for integrationId, uris := range sess.subscriptions.all() {
+		child, ok := lb.getChild(integrationId)
+		if !ok {
+			continue
+		}
+
+		for _, uri := range uris {
+			if err := lb.unsubscribeChild(ctx, child, uri); err != nil {
+				sess.logger.Warn("error unsubscribing from resource", "prefix", child.prefix, "uri", uri, "err", err)
+			}
+		}
+	}
+}
+
+// listSessions returns a snapshot of the sessions, oldest first.
+func (lb *localBroker) listSessions() []*session {
+	lb.sessionsMu.RLock()
+	defer lb.sessionsMu.RUnlock()
+
+	return slices.Clone(lb.sessions)
+}
+
+// listInitializedSessions returns the sessions whose client has completed
+// initialization, oldest first.
+func (lb *localBroker) listInitializedSessions() []*session {
+	return slices.DeleteFunc(lb.listSessions(), func(sess *session) bool {
+		return !sess.initialized.Load()
+	})
+}
+
+// sessionForChild attributes a request issued by a child to the session it
+// is meant for. A request carrying the progress token of a request the broker
+// forwarded to the child belongs to that request's session. Otherwise it goes
+// to the session of the most recent request to the child still in flight,
+// which is presumably what the child is working on, among the sessions
+// eligible to serve it. Nothing is attributable while no eligible session has
+// a request to the child in flight.
+func (lb *localBroker) sessionForChild(child *childServer, meta mcp.Meta, eligible func(*session) bool) (*session, bool) {
+	sessions := lb.listInitializedSessions()
+
+	if token, ok := meta.ProgressToken(); ok {
+		if target, ok := lb.progress.target(token); ok && slices.Contains(sessions, target.session) {
+			return target.session, true
+		}
+	}
+
+	for _, sess := range child.callerSessions() {
+		if slices.Contains(sessions, sess) && eligible(sess) {
+			return sess, true
+		}
+	}
+
+	return nil, false
+}
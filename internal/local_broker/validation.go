@@ -0,0 +1,171 @@
+package localbroker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mcp/internal/mcp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// toolSchemas holds the compiled schemas of a child's tool. Either may be nil
+// when the tool doesn't declare it or it couldn't be compiled.
+type toolSchemas struct {
+	input  *jsonschema.Schema
+	output *jsonschema.Schema
+}
+
+// setChildTools records the tools advertised by a child along with their
+// compiled schemas. Tools whose schemas can't be compiled are still exposed,
+// their calls just aren't validated.
+func (lb *localBroker) setChildTools(child *childServer, tools []mcp.ToolDefinition) {
+	schemas := make(map[string]*toolSchemas, len(tools))
+
+	for _, tool := range tools {
+		s := &toolSchemas{}
+
+		input, err := compileSchema(tool.InputSchema)
+		if err != nil {
+			lb.logger.Warn("error compiling tool input schema", "prefix", child.prefix, "tool", tool.Name, "err", err)
+		}
+		s.input = input
+
+		if tool.OutputSchema != nil {
+			output, err := compileSchema(*tool.OutputSchema)
+			if err != nil {
+				lb.logger.Warn("error compiling tool output schema", "prefix", child.prefix, "tool", tool.Name, "err", err)
+			}
+			s.output = output
+		}
+
+		schemas[tool.Name] = s
+	}
+
+	child.setTools(tools, schemas)
+}
+
+// compileSchema compiles a JSON Schema, defaulting to the 2020-12 draft.
+// Schemas can't reference anything but themselves and the standard
+// meta-schemas: children must not be able to make the broker read files or
+// fetch URLs.
+func compileSchema(schema mcp.JSONSchema) (*jsonschema.Schema, error) {
+	doc, err := jsonValue(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	const url = "mcp://tool/schema.json"
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.UseLoader(jsonschema.SchemeURLLoader{})
+
+	if err := c.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+
+	return c.Compile(url)
+}
+
+// jsonValue converts v into the generic representation expected by the
+// validator, with numbers kept as json.Number.
+func jsonValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonschema.UnmarshalJSON(bytes.NewReader(b))
+}
+
+// validateToolArguments checks the arguments of a call to one of the child's
+// tools against the tool's input schema.
+func validateToolArguments(child *childServer, req *mcp.ToolsCallRequest, toolName string) error {
+	schemas := child.toolSchemas(toolName)
+	if schemas == nil || schemas.input == nil {
+		return nil
+	}
+
+	arguments := req.Arguments
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+
+	if err := validateValue(schemas.input, arguments); err != nil {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: fmt.Sprintf("invalid arguments for tool %q: %s", req.ToolName, err),
+		}
+	}
+
+	return nil
+}
+
+// validateToolResult checks the structured content returned by one of the
+// child's tools against the tool's output schema.
+func validateToolResult(child *childServer, toolName string, result *mcp.ToolsCallResult) error {
+	schemas := child.toolSchemas(toolName)
+	if schemas == nil || schemas.output == nil || result.IsError {
+		return nil
+	}
+
+	errInvalidResult := func(reason string) error {
+		return &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInternalError,
+			Message: fmt.Sprintf("server %q returned an invalid result for tool %q: %s", child.prefix, toolName, reason),
+		}
+	}
+
+	if result.StructuredContent == nil {
+		return errInvalidResult("missing structured content")
+	}
+
+	if err := validateValue(schemas.output, result.StructuredContent); err != nil {
+		return errInvalidResult(err.Error())
+	}
+
+	return nil
+}
+
+// validateValue validates v against schema, describing the first problem
+// found at the deepest location of v.
+func validateValue(schema *jsonschema.Schema, v any) error {
+	value, err := jsonValue(v)
+	if err != nil {
+		return err
+	}
+
+	err = schema.Validate(value)
+	if err == nil {
+		return nil
+	}
+
+	validationErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	var deepest *jsonschema.OutputUnit
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+
+		if deepest == nil || strings.Count(unit.InstanceLocation, "/") > strings.Count(deepest.InstanceLocation, "/") {
+			deepest = &unit
+		}
+	}
+
+	if deepest == nil {
+		return err
+	}
+
+	if deepest.InstanceLocation == "" {
+		return fmt.Errorf("%s", deepest.Error)
+	}
+
+	return fmt.Errorf("%q: %s", deepest.InstanceLocation, deepest.Error)
+}
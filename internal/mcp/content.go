@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	ContentTypeText         = "text"
+	ContentTypeImage        = "image"
+	ContentTypeAudio        = "audio"
+	ContentTypeResource     = "resource"
+	ContentTypeResourceLink = "resource_link"
+)
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Annotations tell the client how to use or display an object.
+type Annotations struct {
+	Audience     []Role   `json:"audience,omitempty"`
+	Priority     *float64 `json:"priority,omitempty"`
+	LastModified string   `json:"lastModified,omitempty"`
+}
+
+type TextContent struct {
+	Type        string       `json:"type"`
+	Text        string       `json:"text"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+type ImageContent struct {
+	Type        string       `json:"type"`
+	Data        string       `json:"data"`
+	MimeType    string       `json:"mimeType"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+type AudioContent struct {
+	Type        string       `json:"type"`
+	Data        string       `json:"data"`
+	MimeType    string       `json:"mimeType"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+// EmbeddedResource carries the contents of a resource inline.
+type EmbeddedResource struct {
+	Type        string           `json:"type"`
+	Resource    ResourceContents `json:"resource"`
+	Annotations *Annotations     `json:"annotations,omitempty"`
+	Meta        Meta             `json:"_meta,omitempty"`
+}
+
+// ResourceLink points at a resource that the client may read.
+type ResourceLink struct {
+	Type        string       `json:"type"`
+	URI         string       `json:"uri"`
+	Name        string       `json:"name"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Size        *int64       `json:"size,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+// Content is one of the kinds of content found in tool results, prompt
+// messages and sampling messages. Exactly one of its fields is set. Content
+// of a kind this package doesn't know about is kept verbatim in Unknown so
+// that it can be relayed untouched.
+type Content struct {
+	Text         *TextContent
+	Image        *ImageContent
+	Audio        *AudioContent
+	Resource     *EmbeddedResource
+	ResourceLink *ResourceLink
+	Unknown      json.RawMessage
+}
+
+func NewTextContent(text string) Content {
+	return Content{
+		Text: &TextContent{
+			Type: ContentTypeText,
+			Text: text,
+		},
+	}
+}
+
+// Type returns the content's type discriminator.
+func (c Content) Type() string {
+	switch {
+	case c.Text != nil:
+		return ContentTypeText
+	case c.Image != nil:
+		return ContentTypeImage
+	case c.Audio != nil:
+		return ContentTypeAudio
+	case c.Resource != nil:
+		return ContentTypeResource
+	case c.ResourceLink != nil:
+		return ContentTypeResourceLink
+	}
+
+	var probe struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(c.Unknown, &probe)
+
+	return probe.Type
+}
+
+func (c Content) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Text != nil:
+		return json.Marshal(c.Text)
+	case c.Image != nil:
+		return json.Marshal(c.Image)
+	case c.Audio != nil:
+		return json.Marshal(c.Audio)
+	case c.Resource != nil:
+		return json.Marshal(c.Resource)
+	case c.ResourceLink != nil:
+		return json.Marshal(c.ResourceLink)
+	case c.Unknown != nil:
+		return c.Unknown, nil
+	}
+
+	return nil, fmt.Errorf("empty content")
+}
+
+func (c *Content) UnmarshalJSON(b []byte) error {
+	var probe struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return err
+	}
+
+	*c = Content{}
+
+	switch probe.Type {
+	case ContentTypeText:
+		c.Text = &TextContent{}
+		return json.Unmarshal(b, c.Text)
+	case ContentTypeImage:
+		c.Image = &ImageContent{}
+		return json.Unmarshal(b, c.Image)
+	case ContentTypeAudio:
+		c.Audio = &AudioContent{}
+		return json.Unmarshal(b, c.Audio)
+	case ContentTypeResource:
+		c.Resource = &EmbeddedResource{}
+		return json.Unmarshal(b, c.Resource)
+	case ContentTypeResourceLink:
+		c.ResourceLink = &ResourceLink{}
+		return json.Unmarshal(b, c.ResourceLink)
+	default:
+		c.Unknown = append(json.RawMessage(nil), b...)
+		return nil
+	}
+}
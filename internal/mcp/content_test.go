@@ -0,0 +1,113 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestContentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		wantType string
+	}{
+		{
+			name:     "text",
+			json:     `{"type": "text", "text": "Tool result text"}`,
+			wantType: ContentTypeText,
+		},
+		{
+			name: "image",
+			json: `{
+				"type": "image",
+				"data": "base64-encoded-data",
+				"mimeType": "image/png",
+				"annotations": {"audience": ["user"], "priority": 0.9}
+			}`,
+			wantType: ContentTypeImage,
+		},
+		{
+			name:     "audio",
+			json:     `{"type": "audio", "data": "base64-encoded-audio-data", "mimeType": "audio/wav"}`,
+			wantType: ContentTypeAudio,
+		},
+		{
+			name: "resource link",
+			json: `{
+				"type": "resource_link",
+				"uri": "file:///project/src/main.rs",
+				"name": "main.rs",
+				"description": "Primary application entry point",
+				"mimeType": "text/x-rust",
+				"annotations": {
+					"audience": ["assistant"],
+					"priority": 0.9,
+					"lastModified": "2025-01-12T15:00:58Z"
+				}
+			}`,
+			wantType: ContentTypeResourceLink,
+		},
+		{
+			name: "embedded text resource",
+			json: `{
+				"type": "resource",
+				"resource": {
+					"uri": "file:///project/src/main.rs",
+					"mimeType": "text/x-rust",
+					"text": "fn main() {\n    println!(\"Hello world!\");\n}"
+				},
+				"annotations": {"audience": ["user", "assistant"], "priority": 0.7}
+			}`,
+			wantType: ContentTypeResource,
+		},
+		{
+			name: "embedded blob resource",
+			json: `{
+				"type": "resource",
+				"resource": {
+					"uri": "file:///project/logo.png",
+					"mimeType": "image/png",
+					"blob": "iVBORw0KGgo="
+				}
+			}`,
+			wantType: ContentTypeResource,
+		},
+		{
+			name:     "empty text",
+			json:     `{"type": "text", "text": ""}`,
+			wantType: ContentTypeText,
+		},
+		{
+			name:     "unknown type",
+			json:     `{"type": "video", "url": "https://example.com/video.mp4", "extra": [1, 2]}`,
+			wantType: "video",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var content Content
+			assertRoundTrip(t, &content, tt.json)
+
+			if got := content.Type(); got != tt.wantType {
+				t.Errorf("Type() = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestContentMarshalEmpty(t *testing.T) {
+	if _, err := json.Marshal(Content{}); err == nil {
+		t.Error("marshalling empty content succeeded, want an error")
+	}
+}
+
+func TestToolsCallResultRoundTrip(t *testing.T) {
+	assertRoundTrip(t, &ToolsCallResult{}, `{
+		"content": [
+			{"type": "text", "text": "{\"temperature\": 22.5}"}
+		],
+		"structuredContent": {"temperature": 22.5},
+		"isError": false
+	}`)
+}
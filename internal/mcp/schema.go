@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// JSONSchema is a JSON Schema as found in tool definitions. The commonly used
+// keywords are exposed as fields; every other keyword, as well as any keyword
+// whose value doesn't fit its field (such as a "type" listing several types)
+// or that its field would omit (such as "required": [] or "default": null),
+// is kept verbatim in Extra so that schemas survive a round trip unchanged.
+type JSONSchema struct {
+	Type        string                 `json:"type,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	Enum        []any                  `json:"enum,omitempty"`
+	Default     any                    `json:"default,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// jsonSchemaFields mirrors JSONSchema without its methods, to let the JSON
+// package do the work for the known keywords.
+type jsonSchemaFields JSONSchema
+
+func (s JSONSchema) MarshalJSON() ([]byte, error) {
+	known, err := json.Marshal(jsonSchemaFields(s))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Extra) == 0 {
+		return known, nil
+	}
+
+	merged := make(map[string]json.RawMessage, len(s.Extra))
+	for k, v := range s.Extra {
+		merged[k] = v
+	}
+
+	var knownFields map[string]json.RawMessage
+	if err := json.Unmarshal(known, &knownFields); err != nil {
+		return nil, err
+	}
+
+	for k, v := range knownFields {
+		merged[k] = v
+	}
+
+	return json.Marshal(merged)
+}
+
+func (s *JSONSchema) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*s = JSONSchema{}
+
+	// Decode the known keywords one at a time so that one holding an
+	// unexpected shape ends up in Extra rather than failing the whole schema,
+	// and so does one present but empty, which marshalling would drop.
+	known := map[string]func(json.RawMessage) error{
+		"type":        decodeKeyword(&s.Type),
+		"title":       decodeKeyword(&s.Title),
+		"description": decodeKeyword(&s.Description),
+		"format":      decodeKeyword(&s.Format),
+		"properties":  decodeKeyword(&s.Properties),
+		"required":    decodeKeyword(&s.Required),
+		"items":       decodeKeyword(&s.Items),
+		"enum":        decodeKeyword(&s.Enum),
+		"default":     decodeKeyword(&s.Default),
+	}
+
+	for k, v := range raw {
+		if decode, ok := known[k]; ok && decode(v) == nil {
+			continue
+		}
+
+		if s.Extra == nil {
+			s.Extra = make(map[string]json.RawMessage)
+		}
+		s.Extra[k] = v
+	}
+
+	return nil
+}
+
+// decodeKeyword returns a function decoding a keyword's value into dst, which
+// is left untouched if the value doesn't fit or is empty.
+func decodeKeyword[T any](dst *T) func(json.RawMessage) error {
+	return func(raw json.RawMessage) error {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+
+		if isEmptyValue(reflect.ValueOf(&v).Elem()) {
+			return errEmptyKeyword
+		}
+
+		*dst = v
+		return nil
+	}
+}
+
+var errEmptyKeyword = errors.New("empty keyword")
+
+// isEmptyValue reports whether omitempty leaves v out when marshalling.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+
+	return v.IsZero()
+}
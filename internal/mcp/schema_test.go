@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONSchemaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "object",
+			json: `{
+				"type": "object",
+				"properties": {
+					"location": {"type": "string", "description": "City name or zip code"}
+				},
+				"required": ["location"]
+			}`,
+		},
+		{
+			name: "array items and enum",
+			json: `{
+				"type": "array",
+				"items": {"type": "string", "enum": ["celsius", "fahrenheit"]},
+				"default": ["celsius"]
+			}`,
+		},
+		{
+			name: "empty properties and required",
+			json: `{"type": "object", "properties": {}, "required": []}`,
+		},
+		{
+			name: "empty description and null default",
+			json: `{"type": "string", "description": "", "default": null}`,
+		},
+		{
+			name: "empty enum",
+			json: `{"enum": []}`,
+		},
+		{
+			name: "zero defaults",
+			json: `{"type": "object", "properties": {"count": {"type": "integer", "default": 0}, "force": {"type": "boolean", "default": false}, "name": {"type": "string", "default": ""}}}`,
+		},
+		{
+			name: "several types",
+			json: `{"type": ["string", "null"]}`,
+		},
+		{
+			name: "unknown keywords",
+			json: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"type": "object",
+				"additionalProperties": false,
+				"properties": {"n": {"type": "number", "minimum": 0, "maximum": 10}}
+			}`,
+		},
+		{
+			name: "empty schema",
+			json: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertRoundTrip(t, &JSONSchema{}, tt.json)
+		})
+	}
+}
+
+func TestJSONSchemaKnownKeywords(t *testing.T) {
+	var schema JSONSchema
+	if err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {"location": {"type": "string"}},
+		"required": ["location"],
+		"additionalProperties": false
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if schema.Type != "object" {
+		t.Errorf("Type = %q, want %q", schema.Type, "object")
+	}
+	if location := schema.Properties["location"]; location == nil || location.Type != "string" {
+		t.Errorf("Properties[location] = %+v, want a string schema", location)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "location" {
+		t.Errorf("Required = %v, want [location]", schema.Required)
+	}
+	if got := string(schema.Extra["additionalProperties"]); got != "false" {
+		t.Errorf("Extra[additionalProperties] = %s, want false", got)
+	}
+}
+
+func TestJSONSchemaModifiedFieldWins(t *testing.T) {
+	var schema JSONSchema
+	if err := json.Unmarshal([]byte(`{"type": "object", "required": []}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	schema.Required = []string{"location"}
+
+	b, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Required) != 1 || got.Required[0] != "location" {
+		t.Errorf("required = %v, want [location]", got.Required)
+	}
+}
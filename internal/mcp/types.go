@@ -2,14 +2,14 @@ package mcp
 
 import (
 	"encoding/json"
+	"slices"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-const MCP_PROTOCOL_VERSION = "2024-11-05"
-
 type ImplementationInfo struct {
 	Name    string `json:"name"`
+	Title   string `json:"title,omitempty"`
 	Version string `json:"version"`
 }
 
@@ -18,11 +18,14 @@ type ListChangesCapability struct {
 }
 
 type SubscribeAndListChangesCapability struct {
+	Subscribe   *bool `json:"subscribe,omitempty"`
 	ListChanged *bool `json:"listChanged,omitempty"`
 }
 
 type LoggingCapability struct{}
 
+type CompletionsCapability struct{}
+
 type SamplingCapability struct{}
 
 type ClientCapabilities struct {
@@ -39,6 +42,7 @@ type InitializeRequest struct {
 
 type ServerCapabilities struct {
 	Experimental map[string]interface{}             `json:"experimental,omitempty"`
+	Completions  *CompletionsCapability             `json:"completions,omitempty"`
 	Logging      *LoggingCapability                 `json:"logging,omitempty"`
 	Prompts      *ListChangesCapability             `json:"prompts,omitempty"`
 	Resources    *SubscribeAndListChangesCapability `json:"resources,omitempty"`
@@ -54,37 +58,314 @@ type InitializeResult struct {
 
 type InitializedNotification struct{}
 
+type EmptyResult struct{}
+
+type ListChangedNotification struct{}
+
 type Meta map[string]any
 
+// ProgressToken returns the token the sender of a request attached to receive
+// progress notifications, if any.
+func (m Meta) ProgressToken() (any, bool) {
+	token, ok := m["progressToken"]
+	return token, ok && token != nil
+}
+
+// CancelledNotification is sent by either side to cancel a request it
+// previously issued.
+type CancelledNotification struct {
+	RequestId jsonrpc2.ID `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+type ProgressNotification struct {
+	ProgressToken any      `json:"progressToken"`
+	Progress      float64  `json:"progress"`
+	Total         *float64 `json:"total,omitempty"`
+	Message       string   `json:"message,omitempty"`
+}
+
 type ToolsCallRequest struct {
+	Meta      Meta           `json:"_meta,omitempty"`
 	ToolName  string         `json:"name"`
 	Arguments map[string]any `json:"arguments"`
 }
 
 type ToolsCallResult struct {
-	Meta    Meta  `json:"_meta,omitempty"`
-	Content []any `json:"content"`
-	IsError bool  `json:"isError"`
+	Meta              Meta      `json:"_meta,omitempty"`
+	Content           []Content `json:"content"`
+	StructuredContent any       `json:"structuredContent,omitempty"`
+	IsError           bool      `json:"isError"`
+}
+
+// ToolAnnotations are hints about a tool's behaviour. Clients must not rely on
+// them when they come from untrusted servers.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    *bool  `json:"readOnlyHint,omitempty"`
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"`
+	IdempotentHint  *bool  `json:"idempotentHint,omitempty"`
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`
 }
 
 type ToolDefinition struct {
+	Name         string           `json:"name"`
+	Title        string           `json:"title,omitempty"`
+	Description  string           `json:"description,omitempty"`
+	InputSchema  JSONSchema       `json:"inputSchema"`
+	OutputSchema *JSONSchema      `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations `json:"annotations,omitempty"`
+	Meta         Meta             `json:"_meta,omitempty"`
+}
+
+type ToolsListRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ToolsListResult struct {
+	Tools      []ToolDefinition `json:"tools"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+type Resource struct {
+	URI         string       `json:"uri"`
+	Name        string       `json:"name"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Size        *int64       `json:"size,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+type ResourceTemplate struct {
+	URITemplate string       `json:"uriTemplate"`
+	Name        string       `json:"name"`
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MimeType    string       `json:"mimeType,omitempty"`
+	Annotations *Annotations `json:"annotations,omitempty"`
+	Meta        Meta         `json:"_meta,omitempty"`
+}
+
+// ResourceContents holds either the text or the base64-encoded binary
+// contents of a resource.
+type ResourceContents struct {
+	URI      string  `json:"uri"`
+	MimeType string  `json:"mimeType,omitempty"`
+	Text     *string `json:"text,omitempty"`
+	Blob     *string `json:"blob,omitempty"`
+	Meta     Meta    `json:"_meta,omitempty"`
+}
+
+type ResourcesListRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ResourcesListResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type ResourceTemplatesListRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ResourceTemplatesListResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+	NextCursor        string             `json:"nextCursor,omitempty"`
+}
+
+type ResourcesReadRequest struct {
+	Meta Meta   `json:"_meta,omitempty"`
+	URI  string `json:"uri"`
+}
+
+type ResourcesReadResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+type ResourcesSubscribeRequest struct {
+	URI string `json:"uri"`
+}
+
+type ResourcesUnsubscribeRequest struct {
+	URI string `json:"uri"`
+}
+
+type ResourceUpdatedNotification struct {
+	URI string `json:"uri"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type Prompt struct {
 	Name        string           `json:"name"`
-	Description string           `json:"description"`
-	InputSchema JSONSchemaObject `json:"inputSchema"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+	Meta        Meta             `json:"_meta,omitempty"`
 }
 
-type JSONSchemaObject struct {
-	Type               string         `json:"type"`
-	Properties         map[string]any `json:"properties"`
-	RequiredProperties []string       `json:"required"`
+type PromptMessage struct {
+	Role    Role    `json:"role"`
+	Content Content `json:"content"`
 }
 
-type JSONSchemaUnknown any
+type PromptsListRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
 
-type ToolsListRequest struct{}
+type PromptsListResult struct {
+	Prompts    []Prompt `json:"prompts"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
 
-type ToolsListResult struct {
-	Tools []ToolDefinition `json:"tools"`
+type PromptsGetRequest struct {
+	Meta      Meta              `json:"_meta,omitempty"`
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type PromptsGetResult struct {
+	Meta        Meta            `json:"_meta,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type ModelHint struct {
+	Name string `json:"name,omitempty"`
+}
+
+type ModelPreferences struct {
+	Hints                []ModelHint `json:"hints,omitempty"`
+	CostPriority         *float64    `json:"costPriority,omitempty"`
+	SpeedPriority        *float64    `json:"speedPriority,omitempty"`
+	IntelligencePriority *float64    `json:"intelligencePriority,omitempty"`
+}
+
+type SamplingMessage struct {
+	Role    Role    `json:"role"`
+	Content Content `json:"content"`
+}
+
+type CreateMessageRequest struct {
+	Meta             Meta              `json:"_meta,omitempty"`
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences *ModelPreferences `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"`
+	Temperature      *float64          `json:"temperature,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+	Metadata         map[string]any    `json:"metadata,omitempty"`
+}
+
+type CreateMessageResult struct {
+	Role       Role    `json:"role"`
+	Content    Content `json:"content"`
+	Model      string  `json:"model"`
+	StopReason string  `json:"stopReason,omitempty"`
+}
+
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+	Meta Meta   `json:"_meta,omitempty"`
+}
+
+type RootsListRequest struct {
+	Meta Meta `json:"_meta,omitempty"`
+}
+
+type RootsListResult struct {
+	Roots []Root `json:"roots"`
+}
+
+const (
+	CompletionRefPrompt   = "ref/prompt"
+	CompletionRefResource = "ref/resource"
+)
+
+// CompletionReference identifies what is being completed: a prompt by name or
+// a resource template by URI template.
+type CompletionReference struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompletionArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CompletionContext struct {
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type CompleteRequest struct {
+	Meta     Meta                `json:"_meta,omitempty"`
+	Ref      CompletionReference `json:"ref"`
+	Argument CompletionArgument  `json:"argument"`
+	Context  *CompletionContext  `json:"context,omitempty"`
+}
+
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   *int     `json:"total,omitempty"`
+	HasMore *bool    `json:"hasMore,omitempty"`
+}
+
+type CompleteResult struct {
+	Completion Completion `json:"completion"`
+}
+
+// LoggingLevel is the severity of a log message, following the syslog levels
+// of RFC 5424.
+type LoggingLevel string
+
+const (
+	LoggingLevelDebug     LoggingLevel = "debug"
+	LoggingLevelInfo      LoggingLevel = "info"
+	LoggingLevelNotice    LoggingLevel = "notice"
+	LoggingLevelWarning   LoggingLevel = "warning"
+	LoggingLevelError     LoggingLevel = "error"
+	LoggingLevelCritical  LoggingLevel = "critical"
+	LoggingLevelAlert     LoggingLevel = "alert"
+	LoggingLevelEmergency LoggingLevel = "emergency"
+)
+
+var loggingLevels = []LoggingLevel{
+	LoggingLevelDebug,
+	LoggingLevelInfo,
+	LoggingLevelNotice,
+	LoggingLevelWarning,
+	LoggingLevelError,
+	LoggingLevelCritical,
+	LoggingLevelAlert,
+	LoggingLevelEmergency,
+}
+
+// Severity orders logging levels from least to most severe. Unknown levels
+// have a severity of -1.
+func (l LoggingLevel) Severity() int {
+	return slices.Index(loggingLevels, l)
+}
+
+type LoggingSetLevelRequest struct {
+	Level LoggingLevel `json:"level"`
+}
+
+type LoggingMessageNotification struct {
+	Level  LoggingLevel `json:"level"`
+	Logger string       `json:"logger,omitempty"`
+	Data   any          `json:"data"`
 }
 
 func MustParams[T any](req *jsonrpc2.Request) (*T, error) {
@@ -104,3 +385,13 @@ func MustParams[T any](req *jsonrpc2.Request) (*T, error) {
 	}
 	return &r, nil
 }
+
+// OptionalParams is like MustParams but yields the zero value of T when the
+// request has no params.
+func OptionalParams[T any](req *jsonrpc2.Request) (*T, error) {
+	if req.Params == nil || string(*req.Params) == "null" {
+		return new(T), nil
+	}
+
+	return MustParams[T](req)
+}
@@ -0,0 +1,186 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// assertRoundTrip unmarshals raw into v and checks that marshalling v again
+// yields the same JSON value.
+func assertRoundTrip(t *testing.T, v any, raw string) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var want, got any
+	if err := json.Unmarshal([]byte(raw), &want); err != nil {
+		t.Fatalf("unmarshal expected: %v", err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal marshalled: %v", err)
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round trip changed the value\nwant: %s\n got: %s", raw, b)
+	}
+}
+
+func TestToolDefinitionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{
+			name: "tool",
+			json: `{
+				"name": "get_weather",
+				"title": "Weather Information Provider",
+				"description": "Get current weather information for a location",
+				"inputSchema": {
+					"type": "object",
+					"properties": {
+						"location": {
+							"type": "string",
+							"description": "City name or zip code"
+						}
+					},
+					"required": ["location"]
+				}
+			}`,
+		},
+		{
+			name: "annotations",
+			json: `{
+				"name": "web_search",
+				"inputSchema": {"type": "object"},
+				"annotations": {
+					"title": "Web Search",
+					"readOnlyHint": true,
+					"openWorldHint": true
+				}
+			}`,
+		},
+		{
+			name: "false hints",
+			json: `{
+				"name": "delete_file",
+				"inputSchema": {"type": "object"},
+				"annotations": {
+					"readOnlyHint": false,
+					"destructiveHint": false,
+					"idempotentHint": false,
+					"openWorldHint": false
+				}
+			}`,
+		},
+		{
+			name: "output schema",
+			json: `{
+				"name": "get_weather_data",
+				"inputSchema": {"type": "object"},
+				"outputSchema": {
+					"type": "object",
+					"properties": {
+						"temperature": {
+							"type": "number",
+							"description": "Temperature in celsius"
+						},
+						"conditions": {
+							"type": "string",
+							"description": "Weather conditions description"
+						}
+					},
+					"required": ["temperature", "conditions"]
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertRoundTrip(t, &ToolDefinition{}, tt.json)
+		})
+	}
+}
+
+func TestToolAnnotationsHints(t *testing.T) {
+	var annotations ToolAnnotations
+	if err := json.Unmarshal([]byte(`{"title": "Delete", "destructiveHint": false}`), &annotations); err != nil {
+		t.Fatal(err)
+	}
+
+	if annotations.Title != "Delete" {
+		t.Errorf("title = %q, want %q", annotations.Title, "Delete")
+	}
+	if annotations.DestructiveHint == nil || *annotations.DestructiveHint {
+		t.Errorf("destructiveHint = %v, want false", annotations.DestructiveHint)
+	}
+	if annotations.ReadOnlyHint != nil {
+		t.Errorf("readOnlyHint = %v, want unset", *annotations.ReadOnlyHint)
+	}
+}
+
+func TestNotificationsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		target func() any
+		json   string
+	}{
+		{
+			name:   "cancelled with string id",
+			target: func() any { return &CancelledNotification{} },
+			json:   `{"requestId": "123", "reason": "User requested cancellation"}`,
+		},
+		{
+			name:   "cancelled with numeric id",
+			target: func() any { return &CancelledNotification{} },
+			json:   `{"requestId": 123}`,
+		},
+		{
+			name:   "progress",
+			target: func() any { return &ProgressNotification{} },
+			json:   `{"progressToken": "abc123", "progress": 50, "total": 100, "message": "Reticulating splines..."}`,
+		},
+		{
+			name:   "progress with numeric token",
+			target: func() any { return &ProgressNotification{} },
+			json:   `{"progressToken": 1, "progress": 0}`,
+		},
+		{
+			name:   "logging message",
+			target: func() any { return &LoggingMessageNotification{} },
+			json: `{
+				"level": "error",
+				"logger": "database",
+				"data": {
+					"error": "Connection failed",
+					"details": {"host": "localhost", "port": 5432}
+				}
+			}`,
+		},
+		{
+			name:   "resource updated",
+			target: func() any { return &ResourceUpdatedNotification{} },
+			json:   `{"uri": "file:///project/src/main.rs"}`,
+		},
+		{
+			name:   "list changed",
+			target: func() any { return &ListChangedNotification{} },
+			json:   `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertRoundTrip(t, tt.target(), tt.json)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package mcp
+
+import "slices"
+
+const (
+	PROTOCOL_VERSION_2024_11_05 = "2024-11-05"
+	PROTOCOL_VERSION_2025_03_26 = "2025-03-26"
+	PROTOCOL_VERSION_2025_06_18 = "2025-06-18"
+)
+
+// MCP_PROTOCOL_VERSION is the latest protocol revision that we support. It is
+// the version we offer when initializing a connection.
+const MCP_PROTOCOL_VERSION = PROTOCOL_VERSION_2025_06_18
+
+// SUPPORTED_PROTOCOL_VERSIONS lists the protocol revisions that we support,
+// newest first.
+var SUPPORTED_PROTOCOL_VERSIONS = []string{
+	PROTOCOL_VERSION_2025_06_18,
+	PROTOCOL_VERSION_2025_03_26,
+	PROTOCOL_VERSION_2024_11_05,
+}
+
+// IsSupportedProtocolVersion reports whether the protocol revision is one we
+// support.
+func IsSupportedProtocolVersion(version string) bool {
+	return slices.Contains(SUPPORTED_PROTOCOL_VERSIONS, version)
+}
+
+// NegotiateProtocolVersion picks the protocol revision to answer an
+// initialize request with. Per the specification, the requested revision is
+// used when supported and the latest supported revision is offered otherwise.
+func NegotiateProtocolVersion(requested string) string {
+	if IsSupportedProtocolVersion(requested) {
+		return requested
+	}
+
+	return MCP_PROTOCOL_VERSION
+}
+
+// ProtocolVersionAtLeast reports whether version is the same as or newer than
+// min. Protocol revisions are dates, so they compare lexically.
+func ProtocolVersionAtLeast(version, min string) bool {
+	return version >= min
+}
@@ -1,12 +1,27 @@
 package registry
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+var ErrIntegrationNotFound = errors.New("integration not found")
+
+// EnvVar describes an environment variable used to configure an integration.
+type EnvVar struct {
+	Key      string `json:"key"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+}
 
 type IntegrationSearchResult struct {
 	Id          string
 	Name        string
 	Version     string
 	Description string
+	Runtime     string
+	EnvVars     []EnvVar
+	Score       float64
 }
 
 type IntegrationManifest struct {
@@ -21,6 +36,15 @@ type IntegrationManifest struct {
 	Runtime     string
 	Command     string
 	Args        []string
+	EnvVars     []EnvVar
+
+	// URL, Transport and Headers locate the server of an integration of the
+	// remote runtime. Headers may reference the integration's environment
+	// variables, such as "Bearer ${API_TOKEN}", to keep secrets out of the
+	// manifest.
+	URL       string
+	Transport string
+	Headers   map[string]string
 }
 
 type RegistryClient interface {
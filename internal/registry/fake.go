@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/blevesearch/bleve/v2"
@@ -69,6 +70,8 @@ func NewFakeClient(logger *slog.Logger) (*fakeClient, error) {
 			Name:        p.Name,
 			Version:     "0.0.1",
 			Description: p.Description,
+			Runtime:     p.Runtime,
+			EnvVars:     p.EnvVars,
 		}
 
 		doc := document.NewDocument(id.String())
@@ -83,7 +86,7 @@ func NewFakeClient(logger *slog.Logger) (*fakeClient, error) {
 		}
 
 		nameField := document.NewTextField("Name", nil, []byte(p.Name))
-		descField := document.NewTextField("Description", nil, []byte(p.Name))
+		descField := document.NewTextField("Description", nil, []byte(p.Description))
 		sourceField := document.NewTextFieldWithIndexingOptions(
 			"_source", nil, mess, index.StoreField)
 
@@ -93,12 +96,32 @@ func NewFakeClient(logger *slog.Logger) (*fakeClient, error) {
 			return nil, err
 		}
 
-		c.pkgs = append(c.pkgs, IntegrationManifest{
+		manifest := IntegrationManifest{
 			Id:          id.String(),
 			Name:        p.Name,
 			Version:     "0.0.1",
 			Description: p.Description,
-		})
+			Vendor:      p.Vendor,
+			SourceURL:   p.SourceURL,
+			License:     p.Licence,
+			Homepage:    p.Homepage,
+			Runtime:     p.Runtime,
+			EnvVars:     p.EnvVars,
+			URL:         p.URL,
+			Transport:   p.Transport,
+			Headers:     p.Headers,
+		}
+
+		switch p.Runtime {
+		case "node":
+			manifest.Command = "npx"
+			manifest.Args = []string{"-y", p.Name}
+		case "python":
+			manifest.Command = "uvx"
+			manifest.Args = []string{p.Name}
+		}
+
+		c.pkgs = append(c.pkgs, manifest)
 	}
 
 	if err := bIndex.Batch(batch); err != nil {
@@ -109,7 +132,23 @@ func NewFakeClient(logger *slog.Logger) (*fakeClient, error) {
 }
 
 func (c *fakeClient) GetIntegrationManifestByNameAndVersion(name, version string) (*IntegrationManifest, error) {
-	return nil, nil
+	for _, p := range c.pkgs {
+		if p.Name != name {
+			continue
+		}
+
+		if version != "" && version != "latest" && version != p.Version {
+			return nil, fmt.Errorf("%w: %s@%s", ErrIntegrationNotFound, name, version)
+		}
+
+		m := p
+		m.Args = slices.Clone(p.Args)
+		m.EnvVars = slices.Clone(p.EnvVars)
+
+		return &m, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrIntegrationNotFound, name)
 }
 
 func (c *fakeClient) SearchIntegrations(ctx context.Context, terms ...string) ([]*IntegrationSearchResult, error) {
@@ -140,6 +179,8 @@ func (c *fakeClient) SearchIntegrations(ctx context.Context, terms ...string) ([
 			return nil, fmt.Errorf("error unmarshalling document %s: %w", hit.ID, err)
 		}
 
+		sr.Score = hit.Score
+
 		pkgs = append(pkgs, &sr)
 	}
 
@@ -2,12 +2,6 @@ package registry
 
 import "encoding/json"
 
-type envVar struct {
-	Key      string `json:"key"`
-	Type     string `json:"type"`
-	Required bool   `json:"required"`
-}
-
 type mcpGetPackage struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
@@ -16,7 +10,11 @@ type mcpGetPackage struct {
 	Homepage    string   `json:"homepage"`
 	Licence     string   `json:"licence"`
 	Runtime     string   `json:"runtime"`
-	EnvVars     []envVar `json:"envVars"`
+	EnvVars     []EnvVar `json:"envVars"`
+
+	URL       string            `json:"url"`
+	Transport string            `json:"transport"`
+	Headers   map[string]string `json:"headers"`
 }
 
 var rawData = []byte(`[
@@ -27,7 +25,14 @@ var rawData = []byte(`[
     "sourceUrl": "https://github.com/modelcontextprotocol/servers/blob/main/src/brave-search",
     "homepage": "https://modelcontextprotocol.io",
     "license": "MIT",
-    "runtime": "node"
+    "runtime": "node",
+    "envVars": [
+      {
+        "key": "BRAVE_API_KEY",
+        "type": "string",
+        "required": true
+      }
+    ]
   },
   {
     "name": "@modelcontextprotocol/server-everything",
@@ -63,7 +68,14 @@ var rawData = []byte(`[
     "sourceUrl": "https://github.com/modelcontextprotocol/servers/blob/main/src/github",
     "homepage": "https://modelcontextprotocol.io",
     "license": "MIT",
-    "runtime": "node"
+    "runtime": "node",
+    "envVars": [
+      {
+        "key": "GITHUB_PERSONAL_ACCESS_TOKEN",
+        "type": "string",
+        "required": true
+      }
+    ]
   },
   {
     "name": "@modelcontextprotocol/server-gitlab",
@@ -72,7 +84,19 @@ var rawData = []byte(`[
     "sourceUrl": "https://github.com/modelcontextprotocol/servers/blob/main/src/gitlab",
     "homepage": "https://modelcontextprotocol.io",
     "license": "MIT",
-    "runtime": "node"
+    "runtime": "node",
+    "envVars": [
+      {
+        "key": "GITLAB_PERSONAL_ACCESS_TOKEN",
+        "type": "string",
+        "required": true
+      },
+      {
+        "key": "GITLAB_API_URL",
+        "type": "string",
+        "required": false
+      }
+    ]
   },
   {
     "name": "@modelcontextprotocol/server-google-maps",
@@ -81,7 +105,14 @@ var rawData = []byte(`[
     "sourceUrl": "https://github.com/modelcontextprotocol/servers/blob/main/src/google-maps",
     "homepage": "https://modelcontextprotocol.io",
     "license": "MIT",
-    "runtime": "node"
+    "runtime": "node",
+    "envVars": [
+      {
+        "key": "GOOGLE_MAPS_API_KEY",
+        "type": "string",
+        "required": true
+      }
+    ]
   },
   {
     "name": "@modelcontextprotocol/server-memory",
@@ -117,7 +148,19 @@ var rawData = []byte(`[
     "sourceUrl": "https://github.com/modelcontextprotocol/servers/blob/main/src/slack",
     "homepage": "https://modelcontextprotocol.io",
     "license": "MIT",
-    "runtime": "node"
+    "runtime": "node",
+    "envVars": [
+      {
+        "key": "SLACK_BOT_TOKEN",
+        "type": "string",
+        "required": true
+      },
+      {
+        "key": "SLACK_TEAM_ID",
+        "type": "string",
+        "required": true
+      }
+    ]
   },
   {
     "name": "@cloudflare/mcp-server-cloudflare",
@@ -0,0 +1,65 @@
+package serverrunner
+
+import (
+	"context"
+	"fmt"
+	"mcp/internal/mcp"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// ServerConn is a live JSON-RPC connection to a server that has completed the
+// MCP initialization handshake.
+type ServerConn interface {
+	Call(ctx context.Context, method string, params, result interface{}, opts ...jsonrpc2.CallOption) error
+	Notify(ctx context.Context, method string, params interface{}, opts ...jsonrpc2.CallOption) error
+
+	// InitializeResult returns the server's response to the initialize request.
+	InitializeResult() *mcp.InitializeResult
+
+	// DisconnectNotify returns a channel that is closed when the connection to
+	// the server is lost.
+	DisconnectNotify() <-chan struct{}
+}
+
+var _ ServerConn = &serverConn{}
+
+type serverConn struct {
+	*jsonrpc2.Conn
+
+	initializeResult *mcp.InitializeResult
+}
+
+func (c *serverConn) InitializeResult() *mcp.InitializeResult {
+	return c.initializeResult
+}
+
+// Initialize performs the MCP initialization handshake over conn, declaring
+// the given client capabilities, and returns a ServerConn once the server is
+// ready to receive requests.
+func Initialize(ctx context.Context, conn *jsonrpc2.Conn, capabilities mcp.ClientCapabilities) (ServerConn, error) {
+	var initializeResult mcp.InitializeResult
+	if err := conn.Call(ctx, "initialize", &mcp.InitializeRequest{
+		ProtocolVersion: mcp.MCP_PROTOCOL_VERSION,
+		Capabilities:    capabilities,
+		ClientInfo: mcp.ImplementationInfo{
+			Name:    "mcp",
+			Version: "0.1.0",
+		},
+	}, &initializeResult); err != nil {
+		return nil, err
+	}
+
+	if !mcp.IsSupportedProtocolVersion(initializeResult.ProtocolVersion) {
+		return nil, fmt.Errorf("unsupported protocol version %q", initializeResult.ProtocolVersion)
+	}
+
+	if err := conn.Notify(ctx, "notifications/initialized", &mcp.InitializedNotification{}); err != nil {
+		return nil, err
+	}
+
+	return &serverConn{
+		Conn:             conn,
+		initializeResult: &initializeResult,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package serverrunner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+var _ ServerStarter = &RuntimeDispatcher{}
+
+// RuntimeDispatcher starts each server with the starter registered for its
+// runtime, so that servers of every runtime can be managed alike.
+type RuntimeDispatcher struct {
+	starters map[string]ServerStarter
+}
+
+// NewRuntimeDispatcher dispatches servers to starters by runtime name, such
+// as "node" or RUNTIME_REMOTE. The same starter may be registered for several
+// runtimes.
+func NewRuntimeDispatcher(starters map[string]ServerStarter) *RuntimeDispatcher {
+	return &RuntimeDispatcher{
+		starters: starters,
+	}
+}
+
+// Close closes every starter once.
+func (d *RuntimeDispatcher) Close() error {
+	var errs error
+	closed := make(map[ServerStarter]bool)
+
+	for _, starter := range d.starters {
+		if closed[starter] {
+			continue
+		}
+		closed[starter] = true
+
+		if err := starter.Close(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func (d *RuntimeDispatcher) Create(ctx context.Context, manifest ServerDescription) (ServerInstance, error) {
+	runtime, err := ParseRuntime(manifest.Runtime)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing runtime: %w", err)
+	}
+
+	starter, ok := d.starters[runtime.Name]
+	if !ok {
+		return nil, fmt.Errorf("no runner for runtime %q", runtime.Name)
+	}
+
+	return starter.Create(ctx, manifest)
+}
@@ -6,12 +6,16 @@ import (
 	"io"
 	"log/slog"
 	"mcp/internal/jsonrpc"
+	"mcp/internal/mcp"
 	serverrunner "mcp/internal/server_runner"
 	"mcp/internal/util"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	docker "github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
@@ -19,6 +23,20 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// PYTHON_IMAGE is the image python servers run in. Unlike the official
+	// python images, it ships uv, whose uvx starts python packages.
+	PYTHON_IMAGE = "ghcr.io/astral-sh/uv"
+
+	// DEFAULT_PYTHON_VERSION is the python version used when a python
+	// runtime doesn't specify one.
+	DEFAULT_PYTHON_VERSION = "3.13"
+
+	// UV_CACHE_DIR is where uv installs packages, on a tmpfs since the root
+	// filesystem of the containers is read-only.
+	UV_CACHE_DIR = "/tmp/uv-cache"
+)
+
 var (
 	DEFAULT_MEMORY_LIMIT_MB      int = 64
 	SERVER_START_TIMEOUT_SECONDS int = 30
@@ -79,7 +97,7 @@ func (r *DockerServerRunner) Create(ctx context.Context, manifest serverrunner.S
 		AttachStderr: true,
 		OpenStdin:    true,
 
-		Cmd: slices.Clone(manifest.Args),
+		Cmd: serverCommand(manifest),
 		Env: envMapToSlice(manifest.Env),
 	}
 
@@ -95,13 +113,22 @@ func (r *DockerServerRunner) Create(ctx context.Context, manifest serverrunner.S
 		AutoRemove: true,
 		Init:       &initTrue,
 		Resources: container.Resources{
-			Memory:           int64(memoryLimitMB),
+			Memory:           int64(memoryLimitMB) * 1024 * 1024,
 			MemorySwap:       0,
 			MemorySwappiness: &memorySwappinessZero,
 		},
 		ReadonlyRootfs: true,
 		DNS:            []string{"8.8.8.8"},
 	}
+
+	for _, m := range manifest.Mounts {
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   m.HostPath,
+			Target:   m.ServerPath,
+			ReadOnly: m.ReadOnly,
+		})
+	}
 	networkingConfig := network.NetworkingConfig{}
 
 	switch runtime.Name {
@@ -109,7 +136,12 @@ func (r *DockerServerRunner) Create(ctx context.Context, manifest serverrunner.S
 		config.Image = "node:" + runtime.Version
 
 	case "python":
-		config.Image = "python:" + runtime.Version
+		config.Image = pythonImage(runtime.Version)
+		config.Env = append(config.Env, "UV_CACHE_DIR="+UV_CACHE_DIR)
+		hostConfig.Tmpfs = map[string]string{"/tmp": ""}
+
+	default:
+		return nil, fmt.Errorf("runtime %q cannot be run in a container", runtime.Name)
 	}
 
 	dsi := &DockerServerInstance{
@@ -118,6 +150,10 @@ func (r *DockerServerRunner) Create(ctx context.Context, manifest serverrunner.S
 		containerConfig:  config,
 		hostConfig:       hostConfig,
 		networkingConfig: networkingConfig,
+		handler:          manifest.Handler,
+		capabilities:     manifest.Capabilities,
+		stderr:           manifest.Stderr,
+		ready:            make(chan struct{}),
 	}
 
 	return dsi, nil
@@ -130,16 +166,23 @@ type DockerServerInstance struct {
 	containerConfig  container.Config
 	hostConfig       container.HostConfig
 	networkingConfig network.NetworkingConfig
+	handler          serverrunner.RequestHandler
+	capabilities     mcp.ClientCapabilities
+	stderr           io.Writer
+
+	ready chan struct{}
+	conn  serverrunner.ServerConn
 }
 
 func (dsi *DockerServerInstance) Run(ctx context.Context) error {
-	defer dsi.docker.Close()
+	// Cleanup must still happen once the server's context has been cancelled.
+	cleanupCtx := context.WithoutCancel(ctx)
 
 	cr, err := dsi.docker.ContainerCreate(ctx, &dsi.containerConfig, &dsi.hostConfig, &dsi.networkingConfig, nil, "")
 	if err != nil {
 		return fmt.Errorf("error creating container: %w", err)
 	}
-	defer dsi.docker.ContainerRemove(ctx, cr.ID, container.RemoveOptions{
+	defer dsi.docker.ContainerRemove(cleanupCtx, cr.ID, container.RemoveOptions{
 		Force: true,
 	})
 
@@ -147,12 +190,12 @@ func (dsi *DockerServerInstance) Run(ctx context.Context) error {
 		return fmt.Errorf("error starting container: %w", err)
 	}
 
-	defer dsi.docker.ContainerStop(ctx, cr.ID, container.StopOptions{
+	defer dsi.docker.ContainerStop(cleanupCtx, cr.ID, container.StopOptions{
 		Timeout: &SERVER_STOP_TIMEOUT_SECONDS,
 	})
 
 	stdoutR, stdoutW := io.Pipe()
-	_, stderrW := io.Pipe()
+	stderrW := &stderrLogger{logger: dsi.logger, w: dsi.stderr}
 
 	// Grab stdin and stdout
 	attachResp, err := dsi.docker.ContainerAttach(ctx, cr.ID, container.AttachOptions{
@@ -187,8 +230,12 @@ func (dsi *DockerServerInstance) Run(ctx context.Context) error {
 	})
 
 	g.Go(func() error {
-		if _, err := stdcopy.StdCopy(stdoutW, stderrW, attachResp.Reader); err != nil && err != io.EOF {
-			return fmt.Errorf("error copying stdio: %w", err)
+		return copyStdio(stdoutW, stderrW, attachResp.Reader)
+	})
+
+	g.Go(func() error {
+		if err := dsi.initialize(ctx, conn); err != nil {
+			return fmt.Errorf("error initializing server: %w", err)
 		}
 		return nil
 	})
@@ -196,13 +243,107 @@ func (dsi *DockerServerInstance) Run(ctx context.Context) error {
 	return g.Wait()
 }
 
+// copyStdio demultiplexes the container's output into stdout and stderr. Once
+// the container's output ends, stdout is closed so that the connection reading
+// it disconnects, with io.EOF when the container exited cleanly.
+func copyStdio(stdout *io.PipeWriter, stderr io.Writer, r io.Reader) error {
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	if err == nil {
+		err = io.EOF
+	}
+
+	stdout.CloseWithError(err)
+
+	if err != io.EOF {
+		return fmt.Errorf("error copying stdio: %w", err)
+	}
+
+	return nil
+}
+
+func (dsi *DockerServerInstance) Conn(ctx context.Context) (serverrunner.ServerConn, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-dsi.ready:
+		return dsi.conn, nil
+	}
+}
+
+// initialize performs the MCP initialization handshake with the server and
+// marks the instance as ready once it completes.
+func (dsi *DockerServerInstance) initialize(ctx context.Context, conn *jsonrpc2.Conn) error {
+	serverConn, err := serverrunner.Initialize(ctx, conn, dsi.capabilities)
+	if err != nil {
+		return err
+	}
+
+	serverInfo := serverConn.InitializeResult().ServerInfo
+	dsi.logger.Debug("server initialized", "name", serverInfo.Name, "version", serverInfo.Version)
+
+	dsi.conn = serverConn
+	close(dsi.ready)
+
+	return nil
+}
+
 func (dsi *DockerServerInstance) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	if req.Method == "ping" {
+		return &mcp.EmptyResult{}, nil
+	}
+
+	if dsi.handler != nil {
+		return dsi.handler(ctx, req)
+	}
+
 	return nil, &jsonrpc2.Error{
 		Code:    jsonrpc2.CodeMethodNotFound,
 		Message: fmt.Sprintf("method %q not found", req.Method),
 	}
 }
 
+// stderrLogger forwards anything the server writes to stderr to the logger
+// and, if set, to w.
+type stderrLogger struct {
+	logger *slog.Logger
+	w      io.Writer
+}
+
+func (l *stderrLogger) Write(p []byte) (int, error) {
+	l.logger.Debug("server stderr", "output", strings.TrimSpace(string(p)))
+
+	if l.w != nil {
+		if _, err := l.w.Write(p); err != nil {
+			l.logger.Error("error forwarding server stderr", "err", err)
+		}
+	}
+
+	return len(p), nil
+}
+
+// serverCommand returns the command line used to start the server inside of
+// its container.
+func serverCommand(manifest serverrunner.ServerDescription) []string {
+	if manifest.Command == "" {
+		return slices.Clone(manifest.Args)
+	}
+
+	return append([]string{manifest.Command}, manifest.Args...)
+}
+
+// pythonImage returns the image running the given python version, which is
+// either "latest" or a semver version of which only the minor version
+// matters.
+func pythonImage(version string) string {
+	if version == "latest" {
+		version = DEFAULT_PYTHON_VERSION
+	} else if v, err := semver.NewVersion(version); err == nil {
+		version = fmt.Sprintf("%d.%d", v.Major(), v.Minor())
+	}
+
+	return fmt.Sprintf("%s:python%s-bookworm-slim", PYTHON_IMAGE, version)
+}
+
 func envMapToSlice(env map[string]string) []string {
 	envSlice := make([]string, 0, len(env))
 	for k, v := range env {
@@ -0,0 +1,84 @@
+package docker_runner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// failingReader yields its data and then fails with err.
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+
+	return n, nil
+}
+
+func TestCopyStdioClosesStdout(t *testing.T) {
+	errBroken := errors.New("connection reset")
+
+	tests := []struct {
+		name      string
+		streamErr error
+		wantErr   bool
+		// readErr is what reading stdout fails with, io.ReadAll reporting no
+		// error when it ends with io.EOF.
+		readErr error
+	}{
+		{
+			name:      "container exited",
+			streamErr: io.EOF,
+		},
+		{
+			name:      "stream broken",
+			streamErr: errBroken,
+			wantErr:   true,
+			readErr:   errBroken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var multiplexed bytes.Buffer
+			stdcopy.NewStdWriter(&multiplexed, stdcopy.Stdout).Write([]byte("{\"jsonrpc\":\"2.0\"}\n"))
+			stdcopy.NewStdWriter(&multiplexed, stdcopy.Stderr).Write([]byte("starting\n"))
+
+			stdoutR, stdoutW := io.Pipe()
+			var stderr bytes.Buffer
+
+			copyErr := make(chan error, 1)
+			go func() {
+				copyErr <- copyStdio(stdoutW, &stderr, &failingReader{data: multiplexed.Bytes(), err: tt.streamErr})
+			}()
+
+			stdout, err := io.ReadAll(stdoutR)
+			if !errors.Is(err, tt.readErr) {
+				t.Errorf("reading stdout failed with %v, want %v", err, tt.readErr)
+			}
+
+			if got := string(stdout); got != "{\"jsonrpc\":\"2.0\"}\n" {
+				t.Errorf("stdout = %q", got)
+			}
+
+			if got := stderr.String(); got != "starting\n" {
+				t.Errorf("stderr = %q", got)
+			}
+
+			if err := <-copyErr; (err != nil) != tt.wantErr {
+				t.Errorf("copyStdio() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package remote_runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	httptransport "mcp/internal/http_transport"
+	"mcp/internal/jsonrpc"
+	"mcp/internal/mcp"
+	serverrunner "mcp/internal/server_runner"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"golang.org/x/sync/errgroup"
+)
+
+var _ serverrunner.ServerStarter = &RemoteServerRunner{}
+
+type RemoteServerOptions struct {
+	// HTTPClient sends the requests to the servers. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// RemoteServerRunner connects to servers of the remote runtime, which are
+// hosted elsewhere and reached over HTTP.
+type RemoteServerRunner struct {
+	logger  *slog.Logger
+	options RemoteServerOptions
+}
+
+func NewRemoteServerRunner(logger *slog.Logger, ops RemoteServerOptions) *RemoteServerRunner {
+	return &RemoteServerRunner{
+		logger:  logger,
+		options: ops,
+	}
+}
+
+func (r *RemoteServerRunner) Close() error {
+	return nil
+}
+
+// Create creates a new server instance from the given manifest.
+//
+// The server is not connected to until Run is called, which blocks for as
+// long as the connection lasts.
+func (r *RemoteServerRunner) Create(ctx context.Context, manifest serverrunner.ServerDescription) (serverrunner.ServerInstance, error) {
+	runtime, err := serverrunner.ParseRuntime(manifest.Runtime)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing runtime: %w", err)
+	}
+
+	if !runtime.IsRemote() {
+		return nil, fmt.Errorf("runtime %q isn't remote", runtime.Name)
+	}
+
+	u, err := url.Parse(manifest.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", manifest.URL)
+	}
+
+	transport := manifest.Transport
+	switch transport {
+	case "":
+		transport = serverrunner.TRANSPORT_STREAMABLE_HTTP
+	case serverrunner.TRANSPORT_STREAMABLE_HTTP, serverrunner.TRANSPORT_SSE:
+	default:
+		return nil, fmt.Errorf("unsupported transport: %s", transport)
+	}
+
+	header := make(http.Header, len(manifest.Headers))
+	for key, value := range manifest.Headers {
+		header.Set(key, os.Expand(value, func(name string) string {
+			return manifest.Env[name]
+		}))
+	}
+
+	rsi := &RemoteServerInstance{
+		logger:       r.logger.With("url", u.Redacted()),
+		url:          u.String(),
+		transport:    transport,
+		handler:      manifest.Handler,
+		capabilities: manifest.Capabilities,
+		clientOptions: httptransport.ClientOptions{
+			HTTPClient: r.options.HTTPClient,
+			Header:     header,
+		},
+		ready: make(chan struct{}),
+	}
+
+	return rsi, nil
+}
+
+type RemoteServerInstance struct {
+	logger *slog.Logger
+
+	url           string
+	transport     string
+	clientOptions httptransport.ClientOptions
+	handler       serverrunner.RequestHandler
+	capabilities  mcp.ClientCapabilities
+
+	ready chan struct{}
+	conn  serverrunner.ServerConn
+}
+
+func (rsi *RemoteServerInstance) Run(ctx context.Context) error {
+	stream, err := rsi.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to server: %w", err)
+	}
+	defer stream.Close()
+
+	handler := jsonrpc2.AsyncHandler(jsonrpc2.HandlerWithError(rsi.handleRequest).SuppressErrClosed())
+	jsonRPCLogger := jsonrpc2.LogMessages(jsonrpc.NewJSONRPCLogger(rsi.logger))
+
+	conn := jsonrpc2.NewConn(ctx, stream, handler, jsonRPCLogger)
+	defer conn.Close()
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-conn.DisconnectNotify():
+			return fmt.Errorf("connection closed")
+		}
+	})
+
+	g.Go(func() error {
+		if err := rsi.initialize(ctx, conn); err != nil {
+			return fmt.Errorf("error initializing server: %w", err)
+		}
+		return nil
+	})
+
+	return g.Wait()
+}
+
+func (rsi *RemoteServerInstance) Conn(ctx context.Context) (serverrunner.ServerConn, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-rsi.ready:
+		return rsi.conn, nil
+	}
+}
+
+// dial opens a stream to the server over its transport.
+func (rsi *RemoteServerInstance) dial(ctx context.Context) (jsonrpc2.ObjectStream, error) {
+	if rsi.transport == serverrunner.TRANSPORT_SSE {
+		return httptransport.DialLegacySSE(ctx, rsi.logger, rsi.url, rsi.clientOptions)
+	}
+
+	return httptransport.NewClientStream(ctx, rsi.logger, rsi.url, rsi.clientOptions), nil
+}
+
+// initialize performs the MCP initialization handshake with the server and
+// marks the instance as ready once it completes.
+func (rsi *RemoteServerInstance) initialize(ctx context.Context, conn *jsonrpc2.Conn) error {
+	serverConn, err := serverrunner.Initialize(ctx, conn, rsi.capabilities)
+	if err != nil {
+		return err
+	}
+
+	serverInfo := serverConn.InitializeResult().ServerInfo
+	rsi.logger.Debug("server initialized", "name", serverInfo.Name, "version", serverInfo.Version)
+
+	rsi.conn = serverConn
+	close(rsi.ready)
+
+	return nil
+}
+
+func (rsi *RemoteServerInstance) handleRequest(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (interface{}, error) {
+	if req.Method == "ping" {
+		return &mcp.EmptyResult{}, nil
+	}
+
+	if rsi.handler != nil {
+		return rsi.handler(ctx, req)
+	}
+
+	return nil, &jsonrpc2.Error{
+		Code:    jsonrpc2.CodeMethodNotFound,
+		Message: fmt.Sprintf("method %q not found", req.Method),
+	}
+}
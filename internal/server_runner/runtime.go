@@ -7,6 +7,16 @@ import (
 	"github.com/Masterminds/semver/v3"
 )
 
+// RUNTIME_REMOTE is the runtime of servers hosted elsewhere and reached over
+// HTTP rather than run locally.
+const RUNTIME_REMOTE = "remote"
+
+// The transports that servers of the remote runtime can be reached over.
+const (
+	TRANSPORT_STREAMABLE_HTTP = "streamable-http"
+	TRANSPORT_SSE             = "sse"
+)
+
 type Runtime struct {
 	Name    string
 	Version string
@@ -18,12 +28,20 @@ func ParseRuntime(spec string) (*Runtime, error) {
 	// If the version is specified, it must be a valid semver version.
 	// If the version is not a valid semver version, return an error.
 	// If the runtime is not a valid runtime, return an error. We support `node`
-	// and `python` runtimes.
+	// and `python` runtimes, as well as the unversioned `remote` runtime.
 
 	specParts := strings.SplitN(spec, "@", 2)
 
 	switch specParts[0] {
 	case "node", "python":
+	case RUNTIME_REMOTE:
+		if len(specParts) > 1 {
+			return nil, fmt.Errorf("the %s runtime has no version", RUNTIME_REMOTE)
+		}
+
+		return &Runtime{
+			Name: RUNTIME_REMOTE,
+		}, nil
 	default:
 		return nil, fmt.Errorf("unsupported runtime: %s", specParts[0])
 	}
@@ -45,3 +63,9 @@ func ParseRuntime(spec string) (*Runtime, error) {
 		Version: version.String(),
 	}, nil
 }
+
+// IsRemote reports whether servers of the runtime are reached over HTTP
+// instead of being run in a container.
+func (r *Runtime) IsRemote() bool {
+	return r.Name == RUNTIME_REMOTE
+}
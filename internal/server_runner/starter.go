@@ -2,15 +2,50 @@ package serverrunner
 
 import (
 	"context"
+	"io"
+	"mcp/internal/mcp"
+
+	"github.com/sourcegraph/jsonrpc2"
 )
 
+// RequestHandler handles the requests and notifications that a server sends
+// to its client.
+type RequestHandler func(ctx context.Context, req *jsonrpc2.Request) (result interface{}, err error)
+
+// Mount makes a directory of the host available to the server.
+type Mount struct {
+	HostPath   string
+	ServerPath string
+	ReadOnly   bool
+}
+
 type ServerDescription struct {
 	Runtime string
 	Command string
 	Args    []string
 	Env     map[string]string
 
+	// URL is the endpoint of a server of the remote runtime, reached over
+	// Transport, which defaults to TRANSPORT_STREAMABLE_HTTP. Headers are sent
+	// with every request, after expanding references to Env such as
+	// "Bearer ${API_TOKEN}".
+	URL       string
+	Transport string
+	Headers   map[string]string
+
 	MemoryLimitMB int
+	Mounts        []Mount
+
+	// Capabilities are the client capabilities declared to the server when
+	// initializing it.
+	Capabilities mcp.ClientCapabilities
+
+	// Handler is invoked for requests and notifications initiated by the
+	// server. When nil, they are rejected as not found.
+	Handler RequestHandler
+
+	// Stderr, when set, receives anything the server writes to stderr.
+	Stderr io.Writer
 }
 
 type StartedServer interface {
@@ -19,6 +54,10 @@ type StartedServer interface {
 
 type ServerInstance interface {
 	Run(ctx context.Context) error
+
+	// Conn returns the live connection to the server. It blocks until the
+	// server has completed the MCP initialization handshake.
+	Conn(ctx context.Context) (ServerConn, error)
 }
 
 type ServerStarter interface {
@@ -0,0 +1,73 @@
+package suggestions
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SuggestedTool is a tool that a model wished existed.
+type SuggestedTool struct {
+	Name        string
+	Description string
+	InputSchema map[string]any
+}
+
+// Suggestion is a suggested tool along with how often it was suggested.
+// Suggestions with similar names are merged into a single Suggestion.
+type Suggestion struct {
+	Id          string
+	Name        string
+	Description string
+	InputSchema map[string]any
+
+	// SessionId identifies the session that first suggested the tool.
+	SessionId       string
+	Count           int
+	CreatedAt       time.Time
+	LastSuggestedAt time.Time
+}
+
+type SuggestionsRepository interface {
+	Close() error
+
+	SuggestTools(ctx context.Context, sessionId string, tools []SuggestedTool) error
+	ListSuggestions(ctx context.Context) ([]*Suggestion, error)
+}
+
+// NormalizeName reduces a tool name to a canonical form so that similar names
+// such as "getWeather", "get_weather" and "Get-Weather" are treated as the
+// same suggestion. A name without any letter or digit has no such form and is
+// kept as is, so that it can't be merged with another.
+func NormalizeName(name string) string {
+	var words []string
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+
+	runes := []rune(strings.TrimSpace(name))
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	if len(words) == 0 {
+		return string(runes)
+	}
+
+	return strings.Join(words, "_")
+}
@@ -0,0 +1,37 @@
+package suggestions
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "getWeather", want: "get_weather"},
+		{name: "get_weather", want: "get_weather"},
+		{name: "Get-Weather", want: "get_weather"},
+		{name: "  get weather  ", want: "get_weather"},
+		{name: "GET_WEATHER", want: "get_weather"},
+		{name: "getHTTPResponse", want: "get_httpresponse"},
+		{name: "get2Forecasts", want: "get2forecasts"},
+		{name: "__get__weather__", want: "get_weather"},
+		{name: "météoDuJour", want: "météo_du_jour"},
+		{name: "???", want: "???"},
+		{name: " !! ", want: "!!"},
+		{name: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeName(tt.name); got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNameDistinguishesSymbolOnlyNames(t *testing.T) {
+	if a, b := NormalizeName("???"), NormalizeName("!!!"); a == b {
+		t.Errorf("NormalizeName(%q) = NormalizeName(%q) = %q", "???", "!!!", a)
+	}
+}
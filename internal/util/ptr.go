@@ -0,0 +1,6 @@
+package util
+
+// Ptr returns a pointer to a copy of v.
+func Ptr[T any](v T) *T {
+	return &v
+}